@@ -0,0 +1,275 @@
+package config
+
+// This module loads the settings of the server from an optional YAML file and the environment.
+// It is the only package that reads either. At startup main hands each section to the Connect or
+// Configure function of the package it belongs to, and those packages keep what they build from
+// it, like the database pool or the redis client, as package state, as they always have. Handlers
+// reach those through the packages' getters rather than being passed the configuration.
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Log        LogConfig        `yaml:"log"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Redis      RedisConfig      `yaml:"redis"`
+	Auth       AuthConfig       `yaml:"auth"`
+	RateLimit  RateLimitConfig  `yaml:"rateLimit"`
+	Moderation ModerationConfig `yaml:"moderation"`
+	Debug      DebugConfig      `yaml:"debug"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Sockets    SocketsConfig    `yaml:"sockets"`
+}
+
+type ServerConfig struct {
+	Addr           string        `yaml:"addr"`
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+	AllowedOrigins []string      `yaml:"allowedOrigins"`
+	// How long to wait for connections to drain when shutting down
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"`
+}
+
+type DatabaseConfig struct {
+	// A lib/pq connection string, either key=value pairs or a postgres:// URL
+	Url string `yaml:"url"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type AuthConfig struct {
+	// Keys tokens are signed with, by kid. Tokens are signed with ActiveKey and verified with
+	// whichever key their kid names.
+	SigningKeys     map[string]string `yaml:"signingKeys"`
+	SigningKeysFile string            `yaml:"signingKeysFile"`
+	ActiveKey       string            `yaml:"activeKey"`
+	// Lets the server start without signing keys, signing tokens with a key that is public. Only
+	// for local development.
+	AllowDevelopmentKey bool `yaml:"allowDevelopmentKey"`
+}
+
+type RateLimitConfig struct {
+	// "memory" or "redis"
+	Store string `yaml:"store"`
+}
+
+type ModerationConfig struct {
+	// "reject", "mask" or "flag"
+	Mode          string   `yaml:"mode"`
+	Languages     []string `yaml:"languages"`
+	WordListFiles []string `yaml:"wordListFiles"`
+	AllowList     []string `yaml:"allowList"`
+}
+
+type DebugConfig struct {
+	// Bearer token for the debug endpoints, which are disabled when it is empty
+	Token string `yaml:"token"`
+}
+
+type TracingConfig struct {
+	// none, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// host:port of the OTLP/HTTP collector
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"serviceName"`
+}
+
+type SocketsConfig struct {
+	// How many messages can wait to be written to a single websocket
+	QueueSize    int           `yaml:"queueSize"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+	// Clients are pinged and declared dead when no pong or message arrives for this long
+	PongTimeout time.Duration `yaml:"pongTimeout"`
+	// What happens when a queue is full: shed skips superseded drawing frames and coalesces
+	// updates before disconnecting, disconnect closes the connection straight away
+	SlowConsumerPolicy string `yaml:"slowConsumerPolicy"`
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":4000",
+			RequestTimeout:  60 * time.Second,
+			AllowedOrigins:  []string{"https://*", "http://*", "ws://*"},
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Log: LogConfig{
+			Level: "debug",
+		},
+		Database: DatabaseConfig{
+			Url: "user=postgres dbname=postgres sslmode=disable password=password",
+		},
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Auth: AuthConfig{
+			SigningKeys: map[string]string{},
+		},
+		RateLimit: RateLimitConfig{
+			Store: "memory",
+		},
+		Moderation: ModerationConfig{
+			Mode: "mask",
+		},
+		Sockets: SocketsConfig{
+			QueueSize:          256,
+			WriteTimeout:       10 * time.Second,
+			PongTimeout:        60 * time.Second,
+			SlowConsumerPolicy: "shed",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			ServiceName: "scribl-clone",
+		},
+	}
+}
+
+// Loads the configuration. Defaults are overridden by the YAML file named by CONFIG_FILE, if any,
+// which is in turn overridden by environment variables.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if err := loadSigningKeysFile(&cfg.Auth); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Reads the signing keys file, which has one kid:secret pair per line.
+func loadSigningKeysFile(auth *AuthConfig) error {
+	if auth.SigningKeysFile == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(auth.SigningKeysFile)
+	if err != nil {
+		return err
+	}
+	return addSigningKeys(auth, strings.Split(string(raw), "\n"))
+}
+
+func addSigningKeys(auth *AuthConfig, pairs []string) error {
+	if auth.SigningKeys == nil {
+		auth.SigningKeys = map[string]string{}
+	}
+	for _, pair := range pairs {
+		pair = strings.TrimSpace(pair)
+		if pair == "" || strings.HasPrefix(pair, "#") {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return errors.New("signing keys must be given as kid:secret")
+		}
+		auth.SigningKeys[id] = secret
+		if auth.ActiveKey == "" {
+			auth.ActiveKey = id
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() error {
+	errs := []error{}
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("server.requestTimeout must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Database.Url == "" {
+		errs = append(errs, errors.New("database.url is required"))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if len(c.Auth.SigningKeys) > 0 {
+		if _, ok := c.Auth.SigningKeys[c.Auth.ActiveKey]; !ok {
+			errs = append(errs, fmt.Errorf("auth.activeKey %q is not one of the signing keys", c.Auth.ActiveKey))
+		}
+	}
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "redis" {
+		errs = append(errs, fmt.Errorf("rateLimit.store must be memory or redis, not %q", c.RateLimit.Store))
+	}
+	switch c.Moderation.Mode {
+	case "reject", "mask", "flag":
+	default:
+		errs = append(errs, fmt.Errorf("moderation.mode must be reject, mask or flag, not %q", c.Moderation.Mode))
+	}
+	if c.Sockets.QueueSize <= 0 {
+		errs = append(errs, errors.New("sockets.queueSize must be positive"))
+	}
+	if c.Sockets.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("sockets.writeTimeout must be positive"))
+	}
+	if c.Sockets.PongTimeout <= 0 {
+		errs = append(errs, errors.New("sockets.pongTimeout must be positive"))
+	}
+	if c.Sockets.SlowConsumerPolicy != "shed" && c.Sockets.SlowConsumerPolicy != "disconnect" {
+		errs = append(errs, fmt.Errorf("sockets.slowConsumerPolicy must be shed or disconnect, not %q", c.Sockets.SlowConsumerPolicy))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, not %q", c.Tracing.Exporter))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return level, fmt.Errorf("log.level: %w", err)
+	}
+	return level, nil
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Overrides the configuration with any of these environment variables that are set.
+func loadEnv(cfg *Config) error {
+	setString(&cfg.Server.Addr, "SERVER_ADDR")
+	if err := setDuration(&cfg.Server.RequestTimeout, "REQUEST_TIMEOUT"); err != nil {
+		return err
+	}
+	setList(&cfg.Server.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	if err := setDuration(&cfg.Server.ShutdownTimeout, "SHUTDOWN_TIMEOUT"); err != nil {
+		return err
+	}
+
+	setString(&cfg.Log.Level, "LOG_LEVEL")
+
+	setString(&cfg.Database.Url, "DATABASE_URL")
+
+	setString(&cfg.Redis.Addr, "REDIS_ADDR")
+	setString(&cfg.Redis.Password, "REDIS_PASSWORD")
+	if err := setInt(&cfg.Redis.DB, "REDIS_DB"); err != nil {
+		return err
+	}
+
+	if keys, ok := os.LookupEnv("JWT_SIGNING_KEYS"); ok {
+		if err := addSigningKeys(&cfg.Auth, strings.Split(keys, ",")); err != nil {
+			return err
+		}
+	}
+	setString(&cfg.Auth.SigningKeysFile, "JWT_SIGNING_KEYS_FILE")
+	setString(&cfg.Auth.ActiveKey, "JWT_ACTIVE_KEY")
+	if err := setBool(&cfg.Auth.AllowDevelopmentKey, "JWT_ALLOW_DEVELOPMENT_KEY"); err != nil {
+		return err
+	}
+
+	setString(&cfg.RateLimit.Store, "RATE_LIMIT_STORE")
+
+	setString(&cfg.Moderation.Mode, "MODERATION_MODE")
+	setList(&cfg.Moderation.Languages, "MODERATION_LANGUAGES")
+	setList(&cfg.Moderation.WordListFiles, "MODERATION_WORD_LIST_FILES")
+	setList(&cfg.Moderation.AllowList, "MODERATION_ALLOW_LIST")
+
+	setString(&cfg.Debug.Token, "DEBUG_TOKEN")
+
+	if err := setInt(&cfg.Sockets.QueueSize, "SOCKET_QUEUE_SIZE"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Sockets.WriteTimeout, "SOCKET_WRITE_TIMEOUT"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Sockets.PongTimeout, "SOCKET_PONG_TIMEOUT"); err != nil {
+		return err
+	}
+	setString(&cfg.Sockets.SlowConsumerPolicy, "SOCKET_SLOW_CONSUMER_POLICY")
+
+	setString(&cfg.Tracing.Exporter, "TRACING_EXPORTER")
+	setString(&cfg.Tracing.Endpoint, "TRACING_ENDPOINT")
+	setString(&cfg.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	return nil
+}
+
+func setString(target *string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = value
+	}
+}
+
+// Lists are comma separated.
+func setList(target *[]string, name string) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*target = list
+}
+
+func setInt(target *int, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func setDuration(target *time.Duration, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*target = parsed
+	return nil
+}
+
+func setBool(target *bool, name string) error {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	*target = parsed
+	return nil
+}
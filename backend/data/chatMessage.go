@@ -0,0 +1,42 @@
+package data
+
+import "time"
+
+const (
+	CHAT_KIND_CHAT    = "chat"
+	CHAT_KIND_GUESS   = "guess"
+	CHAT_KIND_GUESSED = "guessed"
+)
+
+type ChatMessage struct {
+	Id          int       `db:"id" json:"id"`
+	Game        string    `db:"game" json:"game"`
+	Player      string    `db:"player" json:"player"`
+	Message     string    `db:"message" json:"message"`
+	Kind        string    `db:"kind" json:"kind"`
+	Correct     bool      `db:"correct" json:"correct"`
+	Turn        int       `db:"turn" json:"turn"`
+	DateCreated time.Time `db:"date_created" json:"dateCreated"`
+}
+
+func CreateChatMessage(message ChatMessage) error {
+	db := GetDb()
+	message.DateCreated = time.Now()
+
+	_, err := db.NamedExec(`INSERT INTO chat_message
+		(game, player, message, kind, correct, turn, date_created)
+		VALUES
+		(:game, :player, :message, :kind, :correct, :turn, :date_created)
+		`,
+		message,
+	)
+	return err
+}
+
+func GetChatMessages(gameId string) ([]ChatMessage, error) {
+	db := GetDb()
+
+	messages := []ChatMessage{}
+	err := db.Select(&messages, `SELECT * FROM chat_message WHERE game = $1 ORDER BY id`, gameId)
+	return messages, err
+}
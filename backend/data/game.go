@@ -13,6 +13,14 @@ const (
 	GAME_STATE_END                 = 1
 	GAME_STATE_DRAWING             = 2
 	GAME_STATE_SELECTING_WORD      = 3
+
+	DEFAULT_MAX_PLAYERS = 10
+	DEFAULT_LANGUAGE    = "en"
+	DEFAULT_WORD_PACK   = "default"
+
+	// Games older than this are left out of the active games, as abandoned games are only ended
+	// by the expired games sweep once their room code has run out
+	ACTIVE_GAME_MAX_AGE = 24 * time.Hour
 )
 
 type Game struct {
@@ -25,6 +33,64 @@ type Game struct {
 	State               int              `json:"state"`
 	LastStateChangeTime time.Time        `db:"last_state_change_time" json:"lastStateChangeTime"`
 	DateCreated         time.Time        `db:"date_created" json:"dateCreated"`
+	Public              bool             `json:"public"`
+	Language            string           `json:"language"`
+	WordPack            string           `db:"word_pack" json:"wordPack"`
+}
+
+// The fields of a game that are safe to send to every player and spectator. The word is left out,
+// as it is the answer while a turn is being drawn.
+type PublicGame struct {
+	Id                  string           `json:"id"`
+	Rounds              int              `json:"rounds"`
+	CurrentRound        int              `json:"currentRound"`
+	Turn                utils.NullString `json:"turn"`
+	MaxPlayers          int              `json:"maxPlayers"`
+	State               int              `json:"state"`
+	LastStateChangeTime time.Time        `json:"lastStateChangeTime"`
+	DateCreated         time.Time        `json:"dateCreated"`
+	Public              bool             `json:"public"`
+	Language            string           `json:"language"`
+	WordPack            string           `json:"wordPack"`
+}
+
+func (g *Game) WithoutWord() PublicGame {
+	return PublicGame{
+		Id:                  g.Id,
+		Rounds:              g.Rounds,
+		CurrentRound:        g.CurrentRound,
+		Turn:                g.Turn,
+		MaxPlayers:          g.MaxPlayers,
+		State:               g.State,
+		LastStateChangeTime: g.LastStateChangeTime,
+		DateCreated:         g.DateCreated,
+		Public:              g.Public,
+		Language:            g.Language,
+		WordPack:            g.WordPack,
+	}
+}
+
+// The options a game is created with. Public games are listed in the lobby, and words are only
+// offered from the game's language and word pack.
+type GameSettings struct {
+	Public     bool   `json:"public"`
+	Language   string `json:"language"`
+	WordPack   string `json:"wordPack"`
+	MaxPlayers int    `json:"maxPlayers"`
+}
+
+// Fills in the settings that were left out.
+func (s GameSettings) WithDefaults() GameSettings {
+	if s.Language == "" {
+		s.Language = DEFAULT_LANGUAGE
+	}
+	if s.WordPack == "" {
+		s.WordPack = DEFAULT_WORD_PACK
+	}
+	if s.MaxPlayers == 0 {
+		s.MaxPlayers = DEFAULT_MAX_PLAYERS
+	}
+	return s
 }
 
 func GetGame(id string) (*Game, error) {
@@ -45,22 +111,26 @@ func GetGame(id string) (*Game, error) {
 	return &game, nil
 }
 
-func CreateNewGame() (*Game, error) {
+func CreateNewGame(settings GameSettings) (*Game, error) {
 	db := GetDb()
+	settings = settings.WithDefaults()
 	game := Game{
 		Id:           uuid.NewString(),
 		Word:         "",
 		CurrentRound: 1,
 		Turn:         utils.CreateNullString(nil),
-		MaxPlayers:   10,
+		MaxPlayers:   settings.MaxPlayers,
 		State:        GAME_STATE_WAITING_FOR_PLAYERS,
 		DateCreated:  time.Now(),
+		Public:       settings.Public,
+		Language:     settings.Language,
+		WordPack:     settings.WordPack,
 	}
 
 	result := db.QueryRow(`INSERT INTO game
-		(id, word, current_round, turn, max_players, state, date_created)
+		(id, word, current_round, turn, max_players, state, date_created, public, language, word_pack)
 		VALUES
-		($1, $2, $3, $4, $5, $6, $7)
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`,
 		game.Id,
 		game.Word,
@@ -69,6 +139,9 @@ func CreateNewGame() (*Game, error) {
 		game.MaxPlayers,
 		game.State,
 		game.DateCreated,
+		game.Public,
+		game.Language,
+		game.WordPack,
 	)
 
 	if result.Err() != nil {
@@ -76,3 +149,28 @@ func CreateNewGame() (*Game, error) {
 	}
 	return &game, nil
 }
+
+// Returns the number of games in each state, leaving out games that have ended and games older
+// than ACTIVE_GAME_MAX_AGE.
+func CountActiveGames() (map[int]int, error) {
+	rows := []struct {
+		State int
+		Count int
+	}{}
+	err := GetDb().Select(&rows, `
+		SELECT state, count(*) AS count FROM game
+			WHERE state != $1 AND date_created > $2
+			GROUP BY state
+		`,
+		GAME_STATE_END,
+		time.Now().Add(-ACTIVE_GAME_MAX_AGE),
+	)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int]int, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
@@ -1,24 +1,55 @@
 package data
 
 import (
+	"context"
 	"log/slog"
+	"scribl-clone/config"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
+const CONNECT_TIMEOUT = 5 * time.Second
+
 var db *sqlx.DB = nil
 
+// Opens the connection pool. Failing to reach the database isn't fatal, it is reported by the
+// readiness check until the database comes up.
+func Connect(cfg config.DatabaseConfig) error {
+	slog.Debug("connecting to database...")
+	_db, err := sqlx.Open("postgres", cfg.Url)
+	if err != nil {
+		return err
+	}
+	db = _db
+
+	ctx, cancel := context.WithTimeout(context.Background(), CONNECT_TIMEOUT)
+	defer cancel()
+	if err := Ping(ctx); err != nil {
+		slog.Error("could not reach database", "error", err)
+		return nil
+	}
+	slog.Debug("successfully connected to database")
+	return nil
+}
+
+func Ping(ctx context.Context) error {
+	return GetDb().PingContext(ctx)
+}
+
+// Returns the connection pool opened by Connect. Using the database before Connect is a bug, so
+// it panics rather than guessing at credentials.
 func GetDb() *sqlx.DB {
 	if db == nil {
-		slog.Debug("connecting to database...")
-		connStr := "user=postgres dbname=postgres sslmode=disable password=password"
-		_db, err := sqlx.Open("postgres", connStr)
-		if err != nil {
-			slog.Error(err.Error())
-		}
-		slog.Debug("successfully connected to database")
-		db = _db
+		panic("data: database used before Connect")
 	}
 	return db
 }
+
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	return db.Close()
+}
@@ -0,0 +1,83 @@
+package data
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A public game as listed in the lobby.
+type LobbyGame struct {
+	Id           string    `db:"id" json:"id"`
+	State        int       `db:"state" json:"state"`
+	Players      int       `db:"players" json:"players"`
+	MaxPlayers   int       `db:"max_players" json:"maxPlayers"`
+	Language     string    `db:"language" json:"language"`
+	WordPack     string    `db:"word_pack" json:"wordPack"`
+	CurrentRound int       `db:"current_round" json:"currentRound"`
+	Rounds       int       `db:"rounds" json:"rounds"`
+	DateCreated  time.Time `db:"date_created" json:"dateCreated"`
+}
+
+// Narrows down the public games listed in the lobby. Every field is applied by the database, so
+// the limit only cuts off games that match. The zero value matches every game.
+type PublicGamesQuery struct {
+	Language string
+	WordPack string
+	// Any of these states, any state when empty
+	States     []int64
+	MinPlayers int
+	// 0 for no limit
+	MaxPlayers int
+	// Only games that have room for another player
+	Open bool
+}
+
+// Returns up to limit public games matching the query that haven't ended, newest first, with the
+// number of players that haven't been kicked from each.
+func ListPublicGames(query PublicGamesQuery, limit int) ([]LobbyGame, error) {
+	var states pq.Int64Array
+	if len(query.States) > 0 {
+		states = query.States
+	}
+	games := []LobbyGame{}
+	err := GetDb().Select(&games, `SELECT
+			g.id, g.state, count(p.id) AS players, g.max_players, g.language, g.word_pack,
+			g.current_round, g.rounds, g.date_created
+		FROM game g
+		LEFT JOIN player p ON p.game = g.id AND p.active_state != $2
+		WHERE g.public AND g.state != $1
+			AND ($3 = '' OR g.language = $3)
+			AND ($4 = '' OR g.word_pack = $4)
+			AND ($5::integer[] IS NULL OR g.state = ANY($5))
+		GROUP BY g.id
+		HAVING count(p.id) >= $6
+			AND ($7 = 0 OR count(p.id) <= $7)
+			AND (NOT $8 OR count(p.id) < g.max_players)
+		ORDER BY g.date_created DESC
+		LIMIT $9`,
+		GAME_STATE_END,
+		PLAYER_STATE_KICKED,
+		query.Language,
+		query.WordPack,
+		states,
+		query.MinPlayers,
+		query.MaxPlayers,
+		query.Open,
+		limit,
+	)
+	return games, err
+}
+
+// Returns the games created before the time that haven't ended.
+func ListGamesCreatedBefore(before time.Time) ([]string, error) {
+	ids := []string{}
+	err := GetDb().Select(&ids, `SELECT id FROM game WHERE state != $1 AND date_created < $2`, GAME_STATE_END, before)
+	return ids, err
+}
+
+// Marks games as ended, leaving out those that already have.
+func EndGames(ids []string) error {
+	_, err := GetDb().Exec(`UPDATE game SET state = $1 WHERE id = ANY($2) AND state != $1`, GAME_STATE_END, pq.Array(ids))
+	return err
+}
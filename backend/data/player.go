@@ -1,8 +1,8 @@
 package data
 
 import (
-	"log"
-	"log/slog"
+	"database/sql"
+	"errors"
 	"maps"
 	"scribl-clone/utils"
 	"strings"
@@ -11,6 +11,13 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	PLAYER_STATE_CREATING     = "creating"
+	PLAYER_STATE_ACTIVE       = "active"
+	PLAYER_STATE_DISCONNECTED = "disconnected"
+	PLAYER_STATE_KICKED       = "kicked"
+)
+
 type Player struct {
 	Id             string    `db:"id" json:"id"`
 	Name           string    `db:"name" json:"name"`
@@ -21,44 +28,100 @@ type Player struct {
 	ActiveState    string    `db:"active_state" json:"activeState"`
 }
 
-func CreatePlayer(name string, game string) (string, error) {
-	db := GetDb()
+// The fields of a player that are safe to send to other players.
+type PublicPlayer struct {
+	Id             string    `json:"id"`
+	Name           string    `json:"name"`
+	Score          int       `json:"score"`
+	Game           string    `json:"game"`
+	DateCreated    time.Time `json:"dateCreated"`
+	GuessedCorrect bool      `json:"guessedCorrect"`
+	ActiveState    string    `json:"activeState"`
+}
+
+func (p *Player) Public() PublicPlayer {
+	return PublicPlayer{
+		Id:             p.Id,
+		Name:           p.Name,
+		Score:          p.Score,
+		Game:           p.Game,
+		DateCreated:    p.DateCreated,
+		GuessedCorrect: p.GuessedCorrect,
+		ActiveState:    p.ActiveState,
+	}
+}
+
+const PUBLIC_PLAYER_COLUMNS = `id, name, score, game, date_created, guessed_correct, active_state`
+
+// Creates a player in the game if it has room for them, reporting false if it is full. The game
+// is locked while players are counted, so players joining at once can't take it over capacity.
+func CreatePlayer(name string, game string) (string, bool, error) {
 	player := Player{
 		Id:          uuid.NewString(),
 		Name:        name,
 		Score:       0,
 		Game:        game,
 		DateCreated: time.Now(),
-		ActiveState: "creating",
+		ActiveState: PLAYER_STATE_CREATING,
+	}
+
+	tx, err := GetDb().Beginx()
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback()
+
+	var maxPlayers int
+	if err := tx.Get(&maxPlayers, `SELECT max_players FROM game WHERE id = $1 FOR UPDATE`, game); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", false, utils.ErrResourceNotFound
+		}
+		return "", false, err
+	}
+	var players int
+	err = tx.Get(&players, `SELECT count(*) FROM player WHERE game = $1 AND active_state != $2`, game, PLAYER_STATE_KICKED)
+	if err != nil {
+		return "", false, err
+	}
+	if players >= maxPlayers {
+		return "", false, nil
 	}
-	slog.Info(name)
 
-	_, err := db.NamedQuery(`INSERT INTO player
+	_, err = tx.NamedExec(`INSERT INTO player
 		(id, name, score, game, date_created, active_state)
 		VALUES
 		(:id, :name, :score, :game, :date_created, :active_state)
 		`,
 		player,
 	)
-	return player.Id, err
+	if err != nil {
+		return "", false, err
+	}
+	return player.Id, true, tx.Commit()
 }
 
 func GetPlayer(id string) (*Player, error) {
 	db := GetDb()
 
-	query := db.QueryRowx(`SELECT * FROM player WHERE id=$1`, id)
-	if query.Err() != nil {
-		return nil, query.Err()
-	}
-
 	player := Player{}
-	err := query.StructScan(&player)
+	err := db.Get(&player, `SELECT `+PUBLIC_PLAYER_COLUMNS+` FROM player WHERE id=$1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrResourceNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &player, nil
 }
 
+func GetPlayers(gameId string) ([]Player, error) {
+	db := GetDb()
+
+	players := []Player{}
+	err := db.Select(&players, `SELECT `+PUBLIC_PLAYER_COLUMNS+` FROM player WHERE game = $1`, gameId)
+	return players, err
+}
+
 func toSnake(camel string) (snake string) {
 	var b strings.Builder
 	diff := 'a' - 'A'
@@ -86,11 +149,11 @@ func toSnake(camel string) (snake string) {
 
 // TODO: SCRIBBL-1
 func UpdatePlayer(id string, updateSet map[string]any) (*Player, error) {
-	setQuery := dynamicUpdateSet([]string{"Name", "ActiveState"}, updateSet)
+	setQuery := dynamicUpdateSet([]string{"Name"}, updateSet)
 	if setQuery == "" {
 		return nil, utils.ErrInvalidArguments
 	}
-	query := `UPDATE player SET ` + setQuery + " WHERE id = :Id RETURNING *"
+	query := `UPDATE player SET ` + setQuery + " WHERE id = :Id RETURNING " + PUBLIC_PLAYER_COLUMNS
 
 	inputData := map[string]any{"Id": id}
 	maps.Copy(inputData, updateSet)
@@ -98,10 +161,10 @@ func UpdatePlayer(id string, updateSet map[string]any) (*Player, error) {
 	db := GetDb()
 	p := Player{}
 	row, err := db.NamedQuery(query, inputData)
-	log.Println(query)
 	if err != nil {
 		return nil, err
 	}
+	defer row.Close()
 	if !row.Next() {
 		return nil, utils.ErrResourceNotFound
 	}
@@ -111,3 +174,34 @@ func UpdatePlayer(id string, updateSet map[string]any) (*Player, error) {
 	}
 	return &p, nil
 }
+
+// Returns the id of the player who joined the game first, they host the game.
+func GetHostId(gameId string) (string, error) {
+	db := GetDb()
+
+	var hostId string
+	err := db.Get(&hostId, `
+		SELECT id FROM player
+			WHERE game = $1 AND active_state != $2
+			ORDER BY date_created
+			LIMIT 1
+		`,
+		gameId,
+		PLAYER_STATE_KICKED,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", utils.ErrResourceNotFound
+	}
+	return hostId, err
+}
+
+// Moves a player from one activity state to another, reporting whether they were in the expected
+// state. Used for transitions that mustn't override another, like a kicked player disconnecting.
+func TransitionPlayerState(id string, from string, to string) (bool, error) {
+	result, err := GetDb().Exec(`UPDATE player SET active_state = $3 WHERE id = $1 AND active_state = $2`, id, from, to)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
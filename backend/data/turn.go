@@ -0,0 +1,65 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"scribl-clone/utils"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A finished turn of a game, kept so the drawings can be shown once the game is over.
+type Turn struct {
+	Game        string          `db:"game" json:"game"`
+	Number      int             `db:"number" json:"number"`
+	Word        string          `db:"word" json:"word"`
+	Drawer      string          `db:"drawer" json:"drawer"`
+	Guessers    pq.StringArray  `db:"guessers" json:"guessers"`
+	Lines       json.RawMessage `db:"lines" json:"-"`
+	DateCreated time.Time       `db:"date_created" json:"dateCreated"`
+}
+
+func CreateTurn(turn Turn) error {
+	db := GetDb()
+	if turn.Guessers == nil {
+		turn.Guessers = pq.StringArray{}
+	}
+	if turn.Lines == nil {
+		turn.Lines = json.RawMessage("[]")
+	}
+	turn.DateCreated = time.Now()
+
+	_, err := db.NamedExec(`INSERT INTO turn
+		(game, number, word, drawer, guessers, lines, date_created)
+		VALUES
+		(:game, :number, :word, :drawer, :guessers, :lines, :date_created)
+		ON CONFLICT (game, number) DO NOTHING
+		`,
+		turn,
+	)
+	return err
+}
+
+func GetTurn(gameId string, number int) (*Turn, error) {
+	db := GetDb()
+
+	turn := Turn{}
+	err := db.Get(&turn, `SELECT * FROM turn WHERE game = $1 AND number = $2`, gameId, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &turn, nil
+}
+
+func GetTurns(gameId string) ([]Turn, error) {
+	db := GetDb()
+
+	turns := []Turn{}
+	err := db.Select(&turns, `SELECT * FROM turn WHERE game = $1 ORDER BY number`, gameId)
+	return turns, err
+}
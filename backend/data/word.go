@@ -0,0 +1,36 @@
+package data
+
+import "github.com/lib/pq"
+
+// A word from the word bank. Aliases are other answers that are accepted as a correct guess.
+type Word struct {
+	Id       int            `db:"id" json:"id"`
+	Word     string         `db:"word" json:"word"`
+	Language string         `db:"language" json:"language"`
+	Pack     string         `db:"pack" json:"pack"`
+	Aliases  pq.StringArray `db:"aliases" json:"aliases"`
+}
+
+// Returns every alias of the word across all packs. A word not in the word bank has no aliases.
+func GetWordAliases(word string) ([]string, error) {
+	db := GetDb()
+
+	aliases := []string{}
+	err := db.Select(&aliases, `SELECT DISTINCT unnest(aliases) FROM word WHERE lower(word) = lower($1)`, word)
+	return aliases, err
+}
+
+func GetRandomWords(count int, language string, pack string) ([]Word, error) {
+	db := GetDb()
+
+	words := []Word{}
+	err := db.Select(&words, `SELECT * FROM word WHERE language = $2 AND pack = $3 ORDER BY random() LIMIT $1`, count, language, pack)
+	return words, err
+}
+
+// Reports whether the word bank has any words in the pack for the language.
+func WordPackExists(language string, pack string) (bool, error) {
+	exists := false
+	err := GetDb().Get(&exists, `SELECT EXISTS (SELECT 1 FROM word WHERE language = $1 AND pack = $2)`, language, pack)
+	return exists, err
+}
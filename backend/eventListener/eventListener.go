@@ -15,76 +15,98 @@ type channelType struct {
 	lock    sync.RWMutex
 }
 
+// Calls fn with every message published to channel. fn runs on the channel's listener, so it must
+// hand the message off rather than block, or every other subscriber of the channel waits.
 func Subscribe(channel string, key string, fn func(data string)) {
-	rdb := GetPubSub()
+	// subsLock is held throughout so the subscription can't be closed by Unsubscribe in between
+	subsLock.Lock()
+	defer subsLock.Unlock()
 
-	sub, ok := getSubscription(channel)
+	sub, ok := subs[channel]
 	if !ok {
-		sub = new(channelType)
-		sub.clients = make(map[string]func(data string))
-		setSubscription(channel, sub)
-		sub.pubSub = rdb.Subscribe(context.Background(), channel)
+		sub = &channelType{
+			id:      channel,
+			clients: make(map[string]func(data string)),
+			pubSub:  GetPubSub().Subscribe(context.Background(), channel),
+		}
+		subs[channel] = sub
 		go listen(sub)
 	}
+
+	sub.lock.Lock()
 	sub.clients[key] = fn
+	sub.lock.Unlock()
 }
 
 func Unsubscribe(channel string, key string) {
-	sub, ok := getSubscription(channel)
+	subsLock.Lock()
+	defer subsLock.Unlock()
+
+	sub, ok := subs[channel]
 	if !ok {
 		return
 	}
 	sub.lock.Lock()
 	delete(sub.clients, key)
-
-	if len(sub.clients) == 0 {
-		sub.close()
-		deleteSubscription(channel)
-	}
+	empty := len(sub.clients) == 0
 	sub.lock.Unlock()
-}
 
-// ==== PRIVATE ======
-
-var subsLock = sync.RWMutex{}
-var subs = make(map[string]*channelType)
-
-func (s *channelType) close() {
-	s.pubSub.Close()
-
-	subsLock.Lock()
-	defer subsLock.Unlock()
-	delete(subs, s.id)
+	if empty {
+		sub.pubSub.Close()
+		delete(subs, channel)
+	}
 }
 
-func getSubscription(channel string) (*channelType, bool) {
+// Returns the number of clients subscribed to each channel this process listens to.
+func SubscriptionCounts() map[string]int {
 	subsLock.RLock()
 	defer subsLock.RUnlock()
 
-	sub, ok := subs[channel]
-	return sub, ok
-
+	counts := make(map[string]int, len(subs))
+	for channel, sub := range subs {
+		sub.lock.RLock()
+		counts[channel] = len(sub.clients)
+		sub.lock.RUnlock()
+	}
+	return counts
 }
 
-func setSubscription(channel string, sub *channelType) {
+// Closes every subscription and the redis client, used when shutting down.
+func Close() error {
 	subsLock.Lock()
-	defer subsLock.Unlock()
+	for channel, sub := range subs {
+		sub.pubSub.Close()
+		delete(subs, channel)
+	}
+	subsLock.Unlock()
 
-	subs[channel] = sub
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
 }
 
-func deleteSubscription(channel string) {
-	subsLock.Lock()
-	defer subsLock.Unlock()
-	delete(subs, channel)
-}
+// ==== PRIVATE ======
+
+var subsLock = sync.RWMutex{}
+var subs = make(map[string]*channelType)
 
 func listen(sub *channelType) {
 	for msg := range sub.pubSub.Channel() {
-		sub.lock.RLock()
-		for _, handler := range sub.clients {
+		// Handlers are called without the lock so they can unsubscribe
+		for _, handler := range sub.handlers() {
 			handler(msg.Payload)
 		}
-		sub.lock.RUnlock()
 	}
 }
+
+func (s *channelType) handlers() []func(data string) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	handlers := make([]func(data string), 0, len(s.clients))
+	for _, handler := range s.clients {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
@@ -1,19 +1,32 @@
 package eventListener
 
 import (
+	"context"
+	"scribl-clone/config"
+
 	_ "github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 )
 
 var rdb *redis.Client = nil
 
+func Connect(cfg config.RedisConfig) {
+	rdb = redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// Returns the client opened by Connect. Using redis before Connect is a bug, so it panics rather
+// than guessing at an address.
 func GetPubSub() *redis.Client {
 	if rdb == nil {
-		rdb = redis.NewClient(&redis.Options{
-			Addr:     "localhost:6379",
-			Password: "", // no password set
-			DB:       0,  // use default DB
-		})
+		panic("eventListener: redis used before Connect")
 	}
 	return rdb
 }
+
+func Ping(ctx context.Context) error {
+	return GetPubSub().Ping(ctx).Err()
+}
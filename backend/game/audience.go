@@ -0,0 +1,134 @@
+package game
+
+import (
+	"encoding/json"
+	"scribl-clone/data"
+	"slices"
+	"strings"
+)
+
+// Restricts who an event is delivered to. Only, when set, lists the players that receive the
+// event; Except lists players that don't. Spectators, connections that don't belong to a player,
+// only receive events with an Only list when Spectators is set. Events without an audience go to
+// everyone.
+type Audience struct {
+	Only       []string `json:",omitempty"`
+	Except     []string `json:",omitempty"`
+	Spectators bool     `json:",omitempty"`
+}
+
+const (
+	ROLE_DRAWER    = "drawer"
+	ROLE_GUESSED   = "guessed"
+	ROLE_GUESSING  = "guessing"
+	ROLE_SPECTATOR = "spectator"
+)
+
+func ToPlayers(playerIds ...string) *Audience {
+	return &Audience{Only: playerIds}
+}
+
+// Resolves roles to the players that currently hold them. The audience is fixed when the event
+// is published, a player who guesses afterwards won't receive it.
+func ToRoles(gameId string, roles ...string) (*Audience, error) {
+	db := data.GetDb()
+
+	audience := Audience{Only: []string{}}
+	players := []struct {
+		Id             string `db:"id"`
+		GuessedCorrect bool   `db:"guessed_correct"`
+		IsDrawer       bool   `db:"is_drawer"`
+	}{}
+	err := db.Select(&players, `
+		SELECT p.id, p.guessed_correct, COALESCE(p.id = g.turn, false) AS is_drawer
+			FROM player p
+			JOIN game g ON g.id = p.game
+			WHERE p.game = $1
+		`,
+		gameId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range players {
+		role := ROLE_GUESSING
+		if p.IsDrawer {
+			role = ROLE_DRAWER
+		} else if p.GuessedCorrect {
+			role = ROLE_GUESSED
+		}
+		if slices.Contains(roles, role) {
+			audience.Only = append(audience.Only, p.Id)
+		}
+	}
+	audience.Spectators = slices.Contains(roles, ROLE_SPECTATOR)
+	return &audience, nil
+}
+
+func (a *Audience) Includes(playerId string) bool {
+	if a == nil {
+		return true
+	}
+	if playerId == "" {
+		return a.Only == nil || a.Spectators
+	}
+	if a.Only != nil && !slices.Contains(a.Only, playerId) {
+		return false
+	}
+	return !slices.Contains(a.Except, playerId)
+}
+
+// Takes an event as published to the game channel and returns what should be sent to the player,
+// with the audience removed. Returns false when the player shouldn't receive the event at all.
+// An empty playerId is a spectator.
+func PrepareForPlayer(message string, playerId string) (string, bool) {
+	if !strings.Contains(message, `"Audience"`) {
+		return message, true
+	}
+
+	event := GameEvent{}
+	if err := json.Unmarshal([]byte(message), &event); err != nil || event.Audience == nil {
+		return message, true
+	}
+	if !event.Audience.Includes(playerId) {
+		return "", false
+	}
+
+	event.Audience = nil
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// Prepares a recorded event for a replay download: what a spectator would have received, without
+// its sequence number. Returns false when spectators didn't receive the event.
+func PrepareForDownload(message string) (string, bool) {
+	message, ok := PrepareForPlayer(message, "")
+	if !ok {
+		return "", false
+	}
+	if !strings.Contains(message, `"Seq"`) {
+		return message, true
+	}
+
+	event := GameEvent{}
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return "", false
+	}
+	event.Seq = 0
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// Whether anyone can watch a game without playing in it, by spectating, streaming its events or
+// replaying it. Public games are open while they are played, private games only once they have
+// ended, until then only their players can watch them. Spectators never receive the word.
+func OpenToSpectators(g *data.Game) bool {
+	return g.Public || g.State == data.GAME_STATE_END
+}
@@ -0,0 +1,149 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"scribl-clone/data"
+	"scribl-clone/guess"
+	"scribl-clone/moderation"
+	"scribl-clone/ratelimit"
+	"scribl-clone/utils"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const MAX_CHAT_MESSAGE_LENGTH = 200
+
+// A player can send this many chat messages, including guesses sent through chat
+var CHAT_RATE_LIMIT = ratelimit.Limit{Requests: 5, Window: 5 * time.Second}
+
+// Counts the chat and guess rate limits, which apply however the player is connected
+var playerRateLimits ratelimit.Store = ratelimit.NewMemoryStore()
+
+// Sets where chat and guess rate limits are counted, so they can be shared between replicas.
+func SetRateLimitStore(store ratelimit.Store) {
+	playerRateLimits = store
+}
+
+type ChatPayload struct {
+	Message  string `json:"message"`
+	PlayerId string `json:"playerId"`
+}
+
+// Sends a chat message to the game. While a word is being drawn chat is how players guess, so the
+// message is evaluated as a guess; at any other time it is broadcast to everyone.
+func SendChat(gameId string, playerId string, message string) (guess.Result, error) {
+	message = strings.TrimSpace(message)
+	if message == "" || utf8.RuneCountInString(message) > MAX_CHAT_MESSAGE_LENGTH {
+		return guess.RESULT_WRONG, utils.ErrInvalidArguments
+	}
+	allowed, _, err := playerRateLimits.Take(context.Background(), "chat/"+playerId, CHAT_RATE_LIMIT)
+	if err != nil {
+		return guess.RESULT_WRONG, err
+	}
+	if !allowed {
+		return guess.RESULT_WRONG, utils.ErrTooManyRequests
+	}
+
+	db := data.GetDb()
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT state FROM game WHERE id = $1`, gameId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return guess.RESULT_WRONG, utils.ErrResourceNotFound
+		}
+		return guess.RESULT_WRONG, err
+	}
+
+	if g.State == data.GAME_STATE_DRAWING {
+		return SubmitGuess(gameId, playerId, message)
+	}
+
+	var exists bool
+	if err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM player WHERE id = $1 AND game = $2)`, playerId, gameId); err != nil {
+		return guess.RESULT_WRONG, err
+	}
+	if !exists {
+		return guess.RESULT_WRONG, utils.ErrResourceNotFound
+	}
+
+	message, err = moderation.Apply(message)
+	if err != nil {
+		return guess.RESULT_WRONG, err
+	}
+
+	saveChatMessage(gameId, playerId, message, data.CHAT_KIND_CHAT, false)
+	return guess.RESULT_WRONG, publishEvent(gameId, GameEvent{
+		EventType: GAME_EVENT_CHAT,
+		EventPayload: ChatPayload{
+			Message:  message,
+			PlayerId: playerId,
+		},
+	})
+}
+
+// Returns the chat of a game as the player is allowed to see it. During a turn the player has not
+// guessed yet, correct guesses are redacted and the guessed chat is left out, the same as when the
+// messages were sent.
+func GetChatHistory(gameId string, playerId string) ([]data.ChatMessage, error) {
+	messages, err := data.GetChatMessages(gameId)
+	if err != nil {
+		return nil, err
+	}
+
+	db := data.GetDb()
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT state FROM game WHERE id = $1`, gameId); err != nil {
+		return nil, err
+	}
+	if g.State != data.GAME_STATE_DRAWING {
+		return messages, nil
+	}
+
+	knowers, err := ToRoles(gameId, ROLE_DRAWER, ROLE_GUESSED)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(knowers.Only, playerId) {
+		return messages, nil
+	}
+
+	turn, err := GetTurnNumber(gameId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]data.ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Turn == turn && m.Kind == data.CHAT_KIND_GUESSED {
+			continue
+		}
+		if m.Turn == turn && m.Correct && m.Player != playerId {
+			m.Message = ""
+		}
+		visible = append(visible, m)
+	}
+	return visible, nil
+}
+
+func saveChatMessage(gameId string, playerId string, message string, kind string, correct bool) {
+	turn, err := GetTurnNumber(gameId)
+	if err != nil {
+		slog.Error("Error fetching turn number", "gameId", gameId, "error", err)
+	}
+
+	err = data.CreateChatMessage(data.ChatMessage{
+		Game:    gameId,
+		Player:  playerId,
+		Message: message,
+		Kind:    kind,
+		Correct: correct,
+		Turn:    turn,
+	})
+	if err != nil {
+		slog.Error("Error saving chat message", "gameId", gameId, "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Events that only carry the latest values of some fields, so a queued one can absorb a newer one
+// without the client seeing a different end state.
+var COALESCABLE_EVENTS = []int{
+	GAME_EVENT_SCORE_UPDATE,
+	GAME_EVENT_GAME_UPDATE,
+	GAME_EVENT_PLAYER_UPDATE,
+}
+
+func IsCoalescableEvent(eventType int) bool {
+	return slices.Contains(COALESCABLE_EVENTS, eventType)
+}
+
+// Reads the index of the line an encoded drawing event upserts. Each upsert carries the whole line,
+// so a later upsert of the same line in the same turn supersedes an earlier one.
+func ParseDrawingIndex(message string) (int, bool) {
+	event := struct {
+		EventType    int
+		EventPayload struct {
+			Index *int `json:"index"`
+		}
+	}{}
+	if json.Unmarshal([]byte(message), &event) != nil || event.EventType != GAME_EVENT_DRAWING || event.EventPayload.Index == nil {
+		return 0, false
+	}
+	return *event.EventPayload.Index, true
+}
+
+// Merges a newer encoded event into an older one of the same type, returning false if they can't
+// be merged. Only adjacent events should be merged, as the merged event takes the older one's
+// place.
+func CoalesceEvents(older string, newer string) (string, bool) {
+	type encodedEvent struct {
+		EventType    int
+		EventPayload json.RawMessage
+	}
+	a, b := encodedEvent{}, encodedEvent{}
+	if json.Unmarshal([]byte(older), &a) != nil || json.Unmarshal([]byte(newer), &b) != nil {
+		return "", false
+	}
+	if a.EventType != b.EventType || !IsCoalescableEvent(a.EventType) {
+		return "", false
+	}
+
+	var payload any
+	switch a.EventType {
+	case GAME_EVENT_PLAYER_UPDATE:
+		type encodedPlayerUpdate struct {
+			PlayerId string
+			Updates  map[string]json.RawMessage
+		}
+		pa, pb := encodedPlayerUpdate{}, encodedPlayerUpdate{}
+		if json.Unmarshal(a.EventPayload, &pa) != nil || json.Unmarshal(b.EventPayload, &pb) != nil {
+			return "", false
+		}
+		if pa.PlayerId != pb.PlayerId {
+			return "", false
+		}
+		payload = encodedPlayerUpdate{PlayerId: pa.PlayerId, Updates: mergeFields(pa.Updates, pb.Updates)}
+	default:
+		fa, fb := map[string]json.RawMessage{}, map[string]json.RawMessage{}
+		if json.Unmarshal(a.EventPayload, &fa) != nil || json.Unmarshal(b.EventPayload, &fb) != nil {
+			return "", false
+		}
+		payload = mergeFields(fa, fb)
+	}
+
+	merged, err := json.Marshal(GameEvent{EventType: a.EventType, EventPayload: payload})
+	if err != nil {
+		return "", false
+	}
+	return string(merged), true
+}
+
+func mergeFields(older map[string]json.RawMessage, newer map[string]json.RawMessage) map[string]json.RawMessage {
+	if older == nil {
+		older = map[string]json.RawMessage{}
+	}
+	for field, value := range newer {
+		older[field] = value
+	}
+	return older
+}
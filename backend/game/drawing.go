@@ -0,0 +1,189 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/utils"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Lines of a turn are kept in redis while the game is live, keyed by the turn number.
+const DRAWING_TTL = 24 * time.Hour
+
+// Bounds on what a drawer can send, so a single turn can't grow without limit or make renders slow
+const (
+	MIN_LINE_SIZE      = 1
+	MAX_LINE_SIZE      = 100
+	MAX_LINE_POINTS    = 5000
+	MAX_LINES_PER_TURN = 2000
+)
+
+var ErrTurnNotFound = errors.New("turn not found")
+
+func getTurnNumberKey(gameId string) string {
+	return fmt.Sprintf("game/%s/turn", gameId)
+}
+
+func getTurnLinesKey(gameId string, turn int) string {
+	return fmt.Sprintf("game/%s/turn/%d/lines", gameId, turn)
+}
+
+// Starts a new turn, returning its number. Turns are numbered from 1 in the order they are drawn.
+func nextTurnNumber(gameId string) (int, error) {
+	rdb := eventListener.GetPubSub()
+	ctx := context.Background()
+
+	turn, err := rdb.Incr(ctx, getTurnNumberKey(gameId)).Result()
+	if err != nil {
+		return 0, err
+	}
+	rdb.Expire(ctx, getTurnNumberKey(gameId), DRAWING_TTL)
+	return int(turn), nil
+}
+
+// Returns the number of the turn currently being drawn, or 0 if no turn has started.
+func GetTurnNumber(gameId string) (int, error) {
+	rdb := eventListener.GetPubSub()
+	turn, err := rdb.Get(context.Background(), getTurnNumberKey(gameId)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return turn, err
+}
+
+// Returns the line with its size, colour and number of points brought within bounds.
+func clampLine(line Line) Line {
+	line.Size = min(max(line.Size, MIN_LINE_SIZE), MAX_LINE_SIZE)
+	for i := range line.Rgb {
+		line.Rgb[i] = min(max(line.Rgb[i], 0), 255)
+	}
+	if len(line.Points) > MAX_LINE_POINTS {
+		line.Points = line.Points[:MAX_LINE_POINTS]
+	}
+	return line
+}
+
+func storeLine(gameId string, line Line, lineIndex int) error {
+	turn, err := GetTurnNumber(gameId)
+	if err != nil {
+		return err
+	}
+	if turn == 0 {
+		return ErrTurnNotFound
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	rdb := eventListener.GetPubSub()
+	ctx := context.Background()
+	key := getTurnLinesKey(gameId, turn)
+	if err := rdb.HSet(ctx, key, strconv.Itoa(lineIndex), encoded).Err(); err != nil {
+		return err
+	}
+	return rdb.Expire(ctx, key, DRAWING_TTL).Err()
+}
+
+// Returns the lines drawn during a turn. Finished turns are read from the database, the turn
+// currently being drawn from redis.
+func GetLines(gameId string, turn int) ([]Line, error) {
+	t, err := data.GetTurn(gameId, turn)
+	if err == nil {
+		return decodeLines(t.Lines)
+	}
+	if !errors.Is(err, utils.ErrResourceNotFound) {
+		return nil, err
+	}
+	return getLiveLines(gameId, turn)
+}
+
+func decodeLines(encoded []byte) ([]Line, error) {
+	lines := []Line{}
+	err := json.Unmarshal(encoded, &lines)
+	return lines, err
+}
+
+// Returns the lines drawn during a turn that is still in redis, ordered by their index.
+func getLiveLines(gameId string, turn int) ([]Line, error) {
+	currentTurn, err := GetTurnNumber(gameId)
+	if err != nil {
+		return nil, err
+	}
+	if turn < 1 || turn > currentTurn {
+		return nil, ErrTurnNotFound
+	}
+
+	rdb := eventListener.GetPubSub()
+	fields, err := rdb.HGetAll(context.Background(), getTurnLinesKey(gameId, turn)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(fields))
+	for field := range fields {
+		index, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+
+	lines := make([]Line, 0, len(indexes))
+	for _, index := range indexes {
+		line := Line{}
+		if err := json.Unmarshal([]byte(fields[strconv.Itoa(index)]), &line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Persists the turn currently being drawn so its drawing outlives the game.
+func saveTurn(gameId string, drawerId string) error {
+	db := data.GetDb()
+
+	turn, err := GetTurnNumber(gameId)
+	if err != nil || turn == 0 {
+		return err
+	}
+
+	lines, err := getLiveLines(gameId, turn)
+	if err != nil {
+		return err
+	}
+	encodedLines, err := json.Marshal(lines)
+	if err != nil {
+		return err
+	}
+
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT word FROM game WHERE id = $1`, gameId); err != nil {
+		return err
+	}
+
+	guessers := []string{}
+	if err := db.Select(&guessers, `SELECT id FROM player WHERE game = $1 AND guessed_correct = true`, gameId); err != nil {
+		return err
+	}
+
+	return data.CreateTurn(data.Turn{
+		Game:     gameId,
+		Number:   turn,
+		Word:     g.Word,
+		Drawer:   drawerId,
+		Guessers: guessers,
+		Lines:    encodedLines,
+	})
+}
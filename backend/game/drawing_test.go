@@ -0,0 +1,36 @@
+package game
+
+import "testing"
+
+func TestClampLine(t *testing.T) {
+	tooManyPoints := make([]Point, MAX_LINE_POINTS+10)
+
+	tests := []struct {
+		name   string
+		line   Line
+		size   int
+		rgb    [3]int
+		points int
+	}{
+		{"within bounds", Line{Size: 5, Rgb: [3]int{20, 200, 20}, Points: make([]Point, 3)}, 5, [3]int{20, 200, 20}, 3},
+		{"no size", Line{}, MIN_LINE_SIZE, [3]int{}, 0},
+		{"negative size", Line{Size: -4}, MIN_LINE_SIZE, [3]int{}, 0},
+		{"huge size", Line{Size: 1 << 30}, MAX_LINE_SIZE, [3]int{}, 0},
+		{"colour out of range", Line{Size: 5, Rgb: [3]int{-1, 256, 1000}}, 5, [3]int{0, 255, 255}, 0},
+		{"too many points", Line{Size: 5, Points: tooManyPoints}, 5, [3]int{}, MAX_LINE_POINTS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := clampLine(tt.line)
+			if got.Size != tt.size {
+				t.Errorf("size = %d, want %d", got.Size, tt.size)
+			}
+			if got.Rgb != tt.rgb {
+				t.Errorf("rgb = %v, want %v", got.Rgb, tt.rgb)
+			}
+			if len(got.Points) != tt.points {
+				t.Errorf("%d points, want %d", len(got.Points), tt.points)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package game
+
+import "scribl-clone/utils"
+
+// An error caused by a player doing something the state of the game doesn't allow. The message is
+// meant to be shown to the player.
+type GameError struct {
+	Message string
+}
+
+func (e *GameError) Error() string {
+	return e.Message
+}
+
+func (e *GameError) Is(target error) bool {
+	return target == utils.ErrInvalidArguments
+}
+
+var (
+	ErrWrongState        = &GameError{"In wrong state"}
+	ErrNotYourTurn       = &GameError{"It's not your turn"}
+	ErrNotSelectingWord  = &GameError{"It's not time to select a word yet"}
+	ErrNotOffered        = &GameError{"That word wasn't one of your choices"}
+	ErrGameFull          = &GameError{"This game is full"}
+	ErrUnknownWordPack   = &GameError{"There are no words for that language and word pack"}
+	ErrInvalidMaxPlayers = &GameError{"Games can have between 2 and 50 players"}
+	ErrInvalidLine       = &GameError{"That line can't be drawn"}
+)
@@ -5,13 +5,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"scribl-clone/data"
 	"scribl-clone/eventListener"
+	"scribl-clone/metrics"
+	"scribl-clone/replay"
+	"scribl-clone/tracing"
 	"scribl-clone/utils"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Point struct {
@@ -27,6 +35,10 @@ type Line struct {
 type GameEvent struct {
 	EventType    int
 	EventPayload any
+	Audience     *Audience `json:",omitempty"`
+	// Position of the event in the game's recording, clients resuming a stream send back the last
+	// one they saw
+	Seq int64 `json:",omitempty"`
 }
 
 type PlayerUpdatePayload struct {
@@ -49,10 +61,60 @@ const (
 	GAME_EVENT_PLAYER_UPDATE  = 4
 	GAME_EVENT_PLAYER_JOIN    = 5
 	GAME_EVENT_DRAWING        = 6
+	GAME_EVENT_GUESSED_CHAT   = 7
+	GAME_EVENT_WORD_CHOICES   = 8
+	GAME_EVENT_CLOSE_GUESS    = 9
+	GAME_EVENT_CHAT           = 10
+	GAME_EVENT_PLAYER_KICKED  = 11
 
 	ROUND_END_REASON_TIMEOUT = "TIMER_RAN_OUT"
+
+	WORD_CHOICE_COUNT = 3
 )
 
+var gameEventNames = map[int]string{
+	GAME_EVENT_GUESS_OCCURRED: "guess_occurred",
+	GAME_EVENT_SCORE_UPDATE:   "score_update",
+	GAME_EVENT_GAME_UPDATE:    "game_update",
+	GAME_EVENT_PLAYER_UPDATE:  "player_update",
+	GAME_EVENT_PLAYER_JOIN:    "player_join",
+	GAME_EVENT_DRAWING:        "drawing",
+	GAME_EVENT_GUESSED_CHAT:   "guessed_chat",
+	GAME_EVENT_WORD_CHOICES:   "word_choices",
+	GAME_EVENT_CLOSE_GUESS:    "close_guess",
+	GAME_EVENT_CHAT:           "chat",
+	GAME_EVENT_PLAYER_KICKED:  "player_kicked",
+}
+
+func EventTypeName(eventType int) string {
+	if name, ok := gameEventNames[eventType]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Reads the sequence number of an encoded event, 0 if it wasn't recorded.
+func ParseEventSeq(message string) int64 {
+	event := struct{ Seq int64 }{}
+	json.Unmarshal([]byte(message), &event)
+	return event.Seq
+}
+
+// Reads the type of an encoded event. GameEvent is encoded with EventType first, so the rest of
+// the message, which can be a large drawing, doesn't need decoding.
+func ParseEventType(message string) (int, bool) {
+	rest, ok := strings.CutPrefix(message, `{"EventType":`)
+	if !ok {
+		return 0, false
+	}
+	end := strings.IndexByte(rest, ',')
+	if end == -1 {
+		return 0, false
+	}
+	eventType, err := strconv.Atoi(rest[:end])
+	return eventType, err == nil
+}
+
 const (
 	POINTS_DRAWER_TIMEOUT        = 10
 	POINTS_DRAWER_CORRECT_GUESS  = 30
@@ -63,15 +125,58 @@ func GetGameChannelName(gameId string) string {
 	return fmt.Sprintf("game/%s", gameId)
 }
 
+// Returns the id of the game a channel belongs to.
+func ParseGameChannelName(channel string) (string, bool) {
+	return strings.CutPrefix(channel, "game/")
+}
+
 func StartRound(gameId string, drawer string) error {
-	return UpdateGame(gameId, map[string]any{
+	err := UpdateGame(gameId, map[string]any{
 		"state":               data.GAME_STATE_SELECTING_WORD,
 		"turn":                drawer,
 		"lastStateChangeTime": time.Now().UTC().Format(time.RFC3339),
 	})
+	if err != nil {
+		return err
+	}
+	if err := RefreshRoomCode(gameId); err != nil {
+		slog.Error("Error refreshing room code", "gameId", gameId, "error", err)
+	}
+	return OfferWords(gameId, drawer)
+}
+
+// Privately sends the drawer a few words from the word bank to choose from.
+func OfferWords(gameId string, drawer string) error {
+	g := data.Game{}
+	if err := data.GetDb().Get(&g, `SELECT language, word_pack FROM game WHERE id = $1`, gameId); err != nil {
+		return err
+	}
+	words, err := data.GetRandomWords(WORD_CHOICE_COUNT, g.Language, g.WordPack)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return ErrUnknownWordPack
+	}
+
+	choices := make([]string, 0, len(words))
+	for _, w := range words {
+		choices = append(choices, w.Word)
+	}
+	if err := storeWordChoices(gameId, choices); err != nil {
+		return err
+	}
+	return publishEvent(gameId, GameEvent{
+		EventType:    GAME_EVENT_WORD_CHOICES,
+		EventPayload: choices,
+		Audience:     ToPlayers(drawer),
+	})
 }
 
 func SelectWord(gameId string, word string) error {
+	if _, err := nextTurnNumber(gameId); err != nil {
+		slog.Error(err.Error(), "gameId", gameId)
+	}
 	return UpdateGame(gameId, map[string]any{
 		"state":               data.GAME_STATE_DRAWING,
 		"lastStateChangeTime": time.Now().UTC().Format(time.RFC3339),
@@ -87,7 +192,7 @@ func EndTurn(gameId string, reason string) error {
 
 func EndGame(gameId string) error {
 	return UpdateGame(gameId, map[string]any{
-		"state":               data.GAME_STATE_SELECTING_WORD,
+		"state":               data.GAME_STATE_END,
 		"lastStateChangeTime": time.Now().UTC().Format(time.RFC3339),
 	})
 }
@@ -119,11 +224,14 @@ func UpdatePlayer(gameId string, playerId string, playerUpdates map[string]any)
 func AddPlayer(gameId string, player *data.Player) error {
 	return publishEvent(gameId, GameEvent{
 		EventType:    GAME_EVENT_PLAYER_JOIN,
-		EventPayload: player,
+		EventPayload: player.Public(),
 	})
 }
 
 func UpsertLine(gameId string, line Line, lineIndex int) error {
+	if err := storeLine(gameId, line, lineIndex); err != nil {
+		slog.Error(err.Error(), "gameId", gameId)
+	}
 	return publishEvent(gameId, GameEvent{
 		EventType: GAME_EVENT_DRAWING,
 		EventPayload: DrawingEventPayload{
@@ -133,21 +241,85 @@ func UpsertLine(gameId string, line Line, lineIndex int) error {
 	})
 }
 
+type GuessPayload struct {
+	Guess     string `json:"guess"`
+	PlayerId  string `json:"playerId"`
+	IsCorrect bool   `json:"isCorrect"`
+}
+
+// Broadcasts a guess. A correct guess would give the word away, so its text only goes to players
+// who already know the word; everyone else is told that the player guessed it without the guess.
 func GuessOccurred(gameId string, playerId string, guess string, isCorrect bool) error {
+	payload := GuessPayload{
+		Guess:     guess,
+		PlayerId:  playerId,
+		IsCorrect: isCorrect,
+	}
+	if !isCorrect {
+		return publishEvent(gameId, GameEvent{
+			EventType:    GAME_EVENT_GUESS_OCCURRED,
+			EventPayload: payload,
+		})
+	}
+
+	knowers, err := ToRoles(gameId, ROLE_DRAWER, ROLE_GUESSED)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(knowers.Only, playerId) {
+		knowers.Only = append(knowers.Only, playerId)
+	}
+
+	if err := publishEvent(gameId, GameEvent{
+		EventType:    GAME_EVENT_GUESS_OCCURRED,
+		EventPayload: payload,
+		Audience:     knowers,
+	}); err != nil {
+		return err
+	}
+
+	payload.Guess = ""
+	return publishEvent(gameId, GameEvent{
+		EventType:    GAME_EVENT_GUESS_OCCURRED,
+		EventPayload: payload,
+		Audience:     &Audience{Except: knowers.Only},
+	})
+}
+
+// Privately tells a player their guess was nearly right.
+func CloseGuess(gameId string, playerId string, guess string) error {
+	return publishEvent(gameId, GameEvent{
+		EventType: GAME_EVENT_CLOSE_GUESS,
+		EventPayload: struct {
+			Guess string `json:"guess"`
+		}{
+			Guess: guess,
+		},
+		Audience: ToPlayers(playerId),
+	})
+}
+
+// Sends a chat message between the players who know the word, the drawer and those who have
+// guessed it.
+func GuessedChat(gameId string, playerId string, message string) error {
+	knowers, err := ToRoles(gameId, ROLE_DRAWER, ROLE_GUESSED)
+	if err != nil {
+		return err
+	}
 	return publishEvent(gameId, GameEvent{
-		EventType: GAME_EVENT_GUESS_OCCURRED,
+		EventType: GAME_EVENT_GUESSED_CHAT,
 		EventPayload: struct {
-			Guess     string `json:"guess"`
-			PlayerId  string `json:"playerId"`
-			IsCorrect bool   `json:"isCorrect"`
+			Message  string `json:"message"`
+			PlayerId string `json:"playerId"`
 		}{
-			Guess:     guess,
-			PlayerId:  playerId,
-			IsCorrect: isCorrect,
+			Message:  message,
+			PlayerId: playerId,
 		},
+		Audience: knowers,
 	})
 }
 
+// Records the event, so it gets its sequence number, then publishes it.
 func publishEvent(gameId string, event GameEvent) error {
 	pubSub := eventListener.GetPubSub()
 	data, err := json.Marshal(event)
@@ -156,17 +328,39 @@ func publishEvent(gameId string, event GameEvent) error {
 		return err
 	}
 
+	if seq, err := replay.RecordEvent(gameId, data); err != nil {
+		slog.Error("Error recording event", "gameId", gameId, "error", err)
+	} else {
+		event.Seq = seq
+		if data, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	ctx, span := tracing.Start(context.Background(), "publish "+EventTypeName(event.EventType),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("game.id", gameId)),
+	)
+	defer span.End()
+
+	start := time.Now()
 	err = pubSub.Publish(
-		context.Background(),
+		ctx,
 		GetGameChannelName(gameId),
 		string(data),
 	).Err()
+	metrics.PublishDuration.Observe(time.Since(start).Seconds())
 
 	if err != nil {
-		slog.Error(err.Error())
+		span.SetStatus(codes.Error, err.Error())
+		slog.Error(err.Error(), "gameId", gameId)
+		return err
 	}
-
-	return err
+	metrics.EventsPublished.WithLabelValues(EventTypeName(event.EventType)).Inc()
+	if LOBBY_EVENTS[event.EventType] {
+		NotifyLobby(gameId)
+	}
+	return nil
 }
 
 func getNextDrawer(gameId string, currentDrawer string) (playerId string, startNextRound bool, err error) {
@@ -205,6 +399,12 @@ To go to the next round, the following need to happen
 */
 func GotoNextTurn(gameId string, currentDrawerId string) error {
 	db := data.GetDb()
+	observeTurnDuration(gameId)
+	stopTurnTimer(gameId)
+	if err := saveTurn(gameId, currentDrawerId); err != nil {
+		slog.Error("Error saving turn", "gameId", gameId, "error", err)
+	}
+
 	nextDrawerId, startNextRound, err := getNextDrawer(gameId, currentDrawerId)
 	if err != nil {
 		return err
@@ -215,10 +415,11 @@ func GotoNextTurn(gameId string, currentDrawerId string) error {
 		return err
 	}
 
-	newState := utils.If(g.Rounds == g.CurrentRound && startNextRound, data.GAME_STATE_WAITING_FOR_PLAYERS, data.GAME_STATE_SELECTING_WORD)
+	// The game ends once the last round has been drawn
+	newState := utils.If(g.Rounds == g.CurrentRound && startNextRound, data.GAME_STATE_END, data.GAME_STATE_SELECTING_WORD)
 	var nextRound int
-	if newState == data.GAME_STATE_WAITING_FOR_PLAYERS {
-		nextRound = 1
+	if newState == data.GAME_STATE_END {
+		nextRound = g.CurrentRound
 	} else if startNextRound {
 		nextRound = g.CurrentRound + 1
 	} else {
@@ -244,8 +445,13 @@ func GotoNextTurn(gameId string, currentDrawerId string) error {
 	db.Select(&players, `SELECT id FROM player WHERE game = $1`, g.Id)
 
 	for i := range players {
-		log.Println(players[i].Id)
 		UpdatePlayer(gameId, players[i].Id, map[string]any{"GuessedCorrect": false})
 	}
+
+	if newState == data.GAME_STATE_SELECTING_WORD {
+		if err := OfferWords(gameId, nextDrawerId); err != nil {
+			slog.Error("Error offering words", "gameId", gameId, "error", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,160 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"scribl-clone/data"
+	"scribl-clone/guess"
+	"scribl-clone/metrics"
+	"scribl-clone/moderation"
+	"scribl-clone/ratelimit"
+	"scribl-clone/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A player can make this many guesses, over HTTP and the websocket together
+var GUESS_RATE_LIMIT = ratelimit.Limit{Requests: 10, Window: 5 * time.Second}
+
+// Evaluates a guess and broadcasts it. The drawer and players who have already guessed know the
+// word, so what they send goes to the guessed chat instead of being treated as a guess. Moves the
+// game onto the next turn once every player has guessed the word.
+func SubmitGuess(gameId string, playerId string, text string) (guess.Result, error) {
+	db := data.GetDb()
+
+	if strings.TrimSpace(text) == "" {
+		return guess.RESULT_WRONG, utils.ErrInvalidArguments
+	}
+	allowed, _, err := playerRateLimits.Take(context.Background(), "guess/"+playerId, GUESS_RATE_LIMIT)
+	if err != nil {
+		return guess.RESULT_WRONG, err
+	}
+	if !allowed {
+		return guess.RESULT_WRONG, utils.ErrTooManyRequests
+	}
+
+	// The guess is matched as typed, but only the moderated text is shown to other players
+	moderated, err := moderation.Apply(text)
+	if err != nil {
+		return guess.RESULT_WRONG, err
+	}
+
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT id, word, state, turn FROM game WHERE id = $1`, gameId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("Could not find game", "gameId", gameId)
+			return guess.RESULT_WRONG, utils.ErrResourceNotFound
+		}
+		return guess.RESULT_WRONG, err
+	}
+
+	if g.State != data.GAME_STATE_DRAWING {
+		slog.Debug("In wrong state", "gameId", gameId, "playerId", playerId)
+		return guess.RESULT_WRONG, ErrWrongState
+	}
+
+	guesser := data.Player{}
+	if err := db.Get(&guesser, `SELECT id, guessed_correct FROM player WHERE id = $1 AND game = $2`, playerId, gameId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return guess.RESULT_WRONG, utils.ErrResourceNotFound
+		}
+		return guess.RESULT_WRONG, err
+	}
+
+	if g.Turn.String == playerId || guesser.GuessedCorrect {
+		slog.Debug("Requester already knows the word", "gameId", gameId, "playerId", playerId)
+		saveChatMessage(gameId, playerId, moderated, data.CHAT_KIND_GUESSED, false)
+		return guess.RESULT_WRONG, GuessedChat(gameId, playerId, moderated)
+	}
+
+	aliases, err := data.GetWordAliases(g.Word)
+	if err != nil {
+		slog.Error("Error fetching word aliases", "gameId", gameId, "error", err)
+	}
+	result := guess.Match(text, g.Word, aliases)
+	metrics.Guesses.WithLabelValues(result.String()).Inc()
+
+	saveChatMessage(gameId, playerId, moderated, data.CHAT_KIND_GUESS, result.IsCorrect())
+	GuessOccurred(gameId, playerId, moderated, result.IsCorrect())
+
+	if result == guess.RESULT_CLOSE {
+		// Only the guesser learns that they are close
+		slog.Debug("Close guess", "gameId", gameId, "playerId", playerId)
+		CloseGuess(gameId, playerId, moderated)
+		return result, nil
+	}
+	if !result.IsCorrect() {
+		slog.Debug("Incorrect guess", "gameId", gameId, "playerId", playerId)
+		return result, nil
+	}
+
+	// Get players of the game
+	players := []data.Player{}
+	if err := db.Select(&players, `SELECT id, guessed_correct FROM player WHERE game = $1`, gameId); err != nil {
+		return result, err
+	}
+
+	gotoNextRound, err := handleCorrectGuess(gameId, playerId, g.Turn.String, players)
+	if err != nil {
+		return result, err
+	}
+
+	if gotoNextRound {
+		ended, err := endDrawing(gameId, g.Turn.String)
+		if err != nil || !ended {
+			return result, err
+		}
+		if err := GotoNextTurn(gameId, g.Turn.String); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func handleCorrectGuess(gameId string, playerId string, drawer string, players []data.Player) (bool, error) {
+	db := data.GetDb()
+
+	guessingPlayerIndex := slices.IndexFunc(players, func(p data.Player) bool { return p.Id == playerId })
+	if guessingPlayerIndex == -1 {
+		return false, fmt.Errorf("cannot find player with id %s", playerId)
+	}
+
+	if players[guessingPlayerIndex].GuessedCorrect {
+		return false, nil
+	}
+
+	scoreIncrease := 10
+	players[guessingPlayerIndex].GuessedCorrect = true
+
+	var drawerScore int
+	err := db.Get(
+		&drawerScore,
+		`UPDATE player SET 
+			guessed_correct = true, score = score + $2 
+			WHERE id = $1 
+		RETURNING score`,
+		playerId,
+		scoreIncrease)
+
+	if err != nil {
+		return false, err
+	}
+	// TODO
+	UpdatePlayer(gameId, playerId, map[string]any{"GuessedCorrect": true})
+	ScoreUpdate(gameId, map[string]int{playerId: drawerScore})
+
+	return shouldGotoNextRound(players, drawer), nil
+}
+
+func shouldGotoNextRound(players []data.Player, drawer string) bool {
+	for i := range players {
+		if !players[i].GuessedCorrect && players[i].Id != drawer {
+			return false
+		}
+	}
+	return true
+}
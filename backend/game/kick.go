@@ -0,0 +1,57 @@
+package game
+
+import (
+	"encoding/json"
+	"scribl-clone/data"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+	"strings"
+)
+
+type PlayerKickedPayload struct {
+	PlayerId string `json:"playerId"`
+}
+
+// Removes a player from the game. Their tokens are revoked and their connection is closed when
+// the event reaches it.
+func KickPlayer(gameId string, playerId string) error {
+	db := data.GetDb()
+
+	result, err := db.Exec(
+		`UPDATE player SET active_state = $3 WHERE id = $1 AND game = $2`,
+		playerId,
+		gameId,
+		data.PLAYER_STATE_KICKED,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return utils.ErrResourceNotFound
+	}
+
+	if err := player.RevokePlayerTokens(playerId); err != nil {
+		return err
+	}
+
+	UpdatePlayer(gameId, playerId, map[string]any{"ActiveState": data.PLAYER_STATE_KICKED})
+	return publishEvent(gameId, GameEvent{
+		EventType:    GAME_EVENT_PLAYER_KICKED,
+		EventPayload: PlayerKickedPayload{PlayerId: playerId},
+	})
+}
+
+// Reports whether message tells the player they were kicked.
+func IsKickedEvent(message string, playerId string) bool {
+	if playerId == "" || !strings.Contains(message, playerId) {
+		return false
+	}
+	event := struct {
+		EventType    int
+		EventPayload PlayerKickedPayload
+	}{}
+	if err := json.Unmarshal([]byte(message), &event); err != nil {
+		return false
+	}
+	return event.EventType == GAME_EVENT_PLAYER_KICKED && event.EventPayload.PlayerId == playerId
+}
@@ -0,0 +1,264 @@
+package game
+
+// This module lists public games in the lobby and matches players into them. Each list is read from
+// the database at most once every LOBBY_CACHE_TTL per node, and a message is published on
+// LOBBY_CHANNEL whenever a game changes in a way the lobby shows, so lobby feeds know to refresh.
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	LOBBY_CHANNEL = "lobby"
+	// Games read for each list, any more aren't listed
+	LOBBY_MAX_GAMES = 500
+	// How long a node reuses the list it last read
+	LOBBY_CACHE_TTL = time.Second
+	// How often games whose room code was released are ended
+	EXPIRED_GAMES_SWEEP_INTERVAL = 10 * time.Minute
+
+	MIN_MAX_PLAYERS = 2
+	MAX_MAX_PLAYERS = 50
+)
+
+// Events that change what the lobby shows about a game.
+var LOBBY_EVENTS = map[int]bool{
+	GAME_EVENT_GAME_UPDATE:   true,
+	GAME_EVENT_PLAYER_JOIN:   true,
+	GAME_EVENT_PLAYER_KICKED: true,
+}
+
+// A game in the lobby along with the code to join it by.
+type LobbyEntry struct {
+	data.LobbyGame
+	RoomCode string `json:"roomCode"`
+}
+
+// Narrows the lobby down, the zero value matches every game.
+type LobbyFilter struct {
+	Language string
+	WordPack string
+	// Any of these states, any state when empty
+	States     []int
+	MinPlayers int
+	// 0 for no limit
+	MaxPlayers int
+	// Only games that have room for another player
+	Open bool
+}
+
+func (f LobbyFilter) query() data.PublicGamesQuery {
+	states := make([]int64, len(f.States))
+	for i, state := range f.States {
+		states[i] = int64(state)
+	}
+	slices.Sort(states)
+	return data.PublicGamesQuery{
+		Language:   f.Language,
+		WordPack:   f.WordPack,
+		States:     slices.Compact(states),
+		MinPlayers: f.MinPlayers,
+		MaxPlayers: f.MaxPlayers,
+		Open:       f.Open,
+	}
+}
+
+type lobbyRead struct {
+	entries []LobbyEntry
+	fetched time.Time
+}
+
+// Lists read from the database, by their query
+var lobbyCache = struct {
+	lock  sync.Mutex
+	reads map[string]lobbyRead
+}{reads: make(map[string]lobbyRead)}
+
+// Returns the public games matching the filter, read no earlier than LOBBY_CACHE_TTL ago.
+func ListLobby(filter LobbyFilter) ([]LobbyEntry, error) {
+	return ListLobbyAfter(filter, time.Now().Add(-LOBBY_CACHE_TTL))
+}
+
+// Returns the public games matching the filter, read from the database after since. Lobby feeds
+// pass the time a change was reported so they don't miss it.
+func ListLobbyAfter(filter LobbyFilter, since time.Time) ([]LobbyEntry, error) {
+	lobbyCache.lock.Lock()
+	defer lobbyCache.lock.Unlock()
+
+	query := filter.query()
+	key := fmt.Sprint(query)
+	read, ok := lobbyCache.reads[key]
+	if !ok || read.fetched.Before(since) {
+		fetched := time.Now()
+		entries, err := readLobby(query)
+		if err != nil {
+			return nil, err
+		}
+		read = lobbyRead{entries: entries, fetched: fetched}
+		lobbyCache.reads[key] = read
+	}
+	// Filters are chosen by clients, so lists nobody asked for lately are dropped
+	for cached, r := range lobbyCache.reads {
+		if r.fetched.Before(time.Now().Add(-LOBBY_CACHE_TTL)) {
+			delete(lobbyCache.reads, cached)
+		}
+	}
+
+	return slices.Clone(read.entries), nil
+}
+
+func readLobby(query data.PublicGamesQuery) ([]LobbyEntry, error) {
+	games, err := data.ListPublicGames(query, LOBBY_MAX_GAMES)
+	if err != nil {
+		return nil, err
+	}
+	gameIds := make([]string, len(games))
+	for i, g := range games {
+		gameIds[i] = g.Id
+	}
+	codes, err := GetRoomCodes(gameIds)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LobbyEntry, 0, len(games))
+	for _, g := range games {
+		// A game whose code was released has gone quiet and can't be joined by code anymore, it is
+		// ended by the next sweep
+		if code, ok := codes[g.Id]; ok {
+			entries = append(entries, LobbyEntry{LobbyGame: g, RoomCode: code})
+		}
+	}
+	return entries, nil
+}
+
+// Ends the games whose room code was released every EXPIRED_GAMES_SWEEP_INTERVAL until ctx is
+// done, so they stop taking up the lobby. Every node sweeps, ending a game twice does nothing.
+func SweepExpiredGames(ctx context.Context) {
+	ticker := time.NewTicker(EXPIRED_GAMES_SWEEP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if ended, err := endExpiredGames(); err != nil {
+			slog.Error("Error ending expired games", "error", err)
+		} else if ended > 0 {
+			slog.Info("ended expired games", "games", ended)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func endExpiredGames() (int, error) {
+	// A younger game can't have lost its code, it may not have been given it yet
+	gameIds, err := data.ListGamesCreatedBefore(time.Now().Add(-ROOM_CODE_TTL))
+	if err != nil {
+		return 0, err
+	}
+
+	ended := 0
+	for start := 0; start < len(gameIds); start += LOBBY_MAX_GAMES {
+		batch := gameIds[start:min(start+LOBBY_MAX_GAMES, len(gameIds))]
+		codes, err := GetRoomCodes(batch)
+		if err != nil {
+			return ended, err
+		}
+		expired := []string{}
+		for _, gameId := range batch {
+			if _, ok := codes[gameId]; !ok {
+				expired = append(expired, gameId)
+			}
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		if err := data.EndGames(expired); err != nil {
+			return ended, err
+		}
+		ended += len(expired)
+	}
+	return ended, nil
+}
+
+// Calls onChange whenever the lobby may have changed, until the returned function is called.
+// onChange runs on the lobby channel's listener, so it must not block.
+func WatchLobby(id string, onChange func()) func() {
+	eventListener.Subscribe(LOBBY_CHANNEL, id, func(string) { onChange() })
+	return func() { eventListener.Unsubscribe(LOBBY_CHANNEL, id) }
+}
+
+// Tells lobby feeds that a game has changed.
+func NotifyLobby(gameId string) {
+	if err := eventListener.GetPubSub().Publish(context.Background(), LOBBY_CHANNEL, gameId).Err(); err != nil {
+		slog.Error("Error notifying lobby", "gameId", gameId, "error", err)
+	}
+}
+
+// Creates a game with the settings and gives it a room code.
+func NewGame(settings data.GameSettings) (*data.Game, string, error) {
+	settings = settings.WithDefaults()
+	if settings.MaxPlayers < MIN_MAX_PLAYERS || settings.MaxPlayers > MAX_MAX_PLAYERS {
+		return nil, "", ErrInvalidMaxPlayers
+	}
+	// The default pack is seeded by the migrations, other packs have to be added first
+	if settings.Language != data.DEFAULT_LANGUAGE || settings.WordPack != data.DEFAULT_WORD_PACK {
+		exists, err := data.WordPackExists(settings.Language, settings.WordPack)
+		if err != nil {
+			return nil, "", err
+		}
+		if !exists {
+			return nil, "", ErrUnknownWordPack
+		}
+	}
+
+	g, err := data.CreateNewGame(settings)
+	if err != nil {
+		return nil, "", err
+	}
+	code, err := AssignRoomCode(g.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	if g.Public {
+		NotifyLobby(g.Id)
+	}
+	return g, code, nil
+}
+
+// Finds the best public game in the language and word pack with room for another player: the one
+// with the most players, preferring games that haven't started, then the oldest. Returns "" when
+// there is none.
+func FindQuickPlayGame(language string, wordPack string) (string, error) {
+	entries, err := ListLobby(LobbyFilter{Language: language, WordPack: wordPack, Open: true})
+	if err != nil || len(entries) == 0 {
+		return "", err
+	}
+
+	best := slices.MinFunc(entries, func(a LobbyEntry, b LobbyEntry) int {
+		if c := cmp.Compare(b.Players, a.Players); c != 0 {
+			return c
+		}
+		aWaiting := a.State == data.GAME_STATE_WAITING_FOR_PLAYERS
+		bWaiting := b.State == data.GAME_STATE_WAITING_FOR_PLAYERS
+		if aWaiting != bWaiting {
+			if aWaiting {
+				return -1
+			}
+			return 1
+		}
+		return a.DateCreated.Compare(b.DateCreated)
+	})
+	return best.Id, nil
+}
@@ -0,0 +1,340 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"scribl-clone/eventListener"
+	"scribl-clone/lease"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How long a node owns a game without renewing. A node that dies loses its games after this long
+// and another node takes over their timers.
+const OWNER_LEASE_TTL = 15 * time.Second
+
+func getGameOwnerKey(gameId string) string {
+	return fmt.Sprintf("game/%s/owner", gameId)
+}
+
+func getNodeChannelName(nodeId string) string {
+	return fmt.Sprintf("node/%s", nodeId)
+}
+
+type NodeOptions struct {
+	LeaseTTL time.Duration
+	// Ends a turn that ran out of time, endTurnOnTimeout unless replaced
+	OnTimeout func(gameId string, drawerId string)
+}
+
+// One replica of the server. Each game with a turn being drawn is owned by a single node, which
+// runs the timer that ends the turn. Other nodes hand the timer to the owner, and nodes adopt the
+// games of owners whose lease ran out.
+//
+// Only timers are owned. Other transitions, like a correct guess ending the turn, run on the node
+// that received the request, and are made with conditional updates so that when two nodes make
+// the same transition at once only one of them applies it.
+type Node struct {
+	Id        string
+	leases    *lease.Leases
+	onTimeout func(gameId string, drawerId string)
+
+	lock   sync.Mutex
+	timers map[string]*time.Timer
+	// Timers that haven't been stopped and may still fire
+	firing   sync.WaitGroup
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var defaultNode struct {
+	once sync.Once
+	node *Node
+}
+
+// Returns the node of this process.
+func DefaultNode() *Node {
+	defaultNode.once.Do(func() {
+		hostname, _ := os.Hostname()
+		defaultNode.node = NewNode(hostname+"/"+uuid.NewString()[:8], NodeOptions{})
+	})
+	return defaultNode.node
+}
+
+func NewNode(id string, options NodeOptions) *Node {
+	if options.LeaseTTL == 0 {
+		options.LeaseTTL = OWNER_LEASE_TTL
+	}
+	if options.OnTimeout == nil {
+		options.OnTimeout = endTurnOnTimeout
+	}
+	return &Node{
+		Id:        id,
+		leases:    lease.New(eventListener.GetPubSub(), options.LeaseTTL),
+		onTimeout: options.OnTimeout,
+		timers:    make(map[string]*time.Timer),
+		done:      make(chan struct{}),
+	}
+}
+
+// Starts listening for games handed over by other nodes, renewing leases and adopting games
+// without an owner. Deadlines that passed while no node owned the game end straight away.
+func (n *Node) Start() error {
+	eventListener.Subscribe(getNodeChannelName(n.Id), n.Id, func(gameId string) {
+		go n.armFromStore(gameId)
+	})
+	adopted, err := n.adoptOrphans()
+	if err != nil {
+		return err
+	}
+	slog.Info("node started", "nodeId", n.Id, "adoptedGames", adopted)
+
+	go n.maintain()
+	return nil
+}
+
+// Stops the node's timers and gives up its games, so other nodes take them over without waiting
+// for the leases to run out. Deadlines stay in redis. Returns once timers that already fired have
+// finished.
+func (n *Node) Stop() {
+	for _, gameId := range n.shutdown() {
+		if err := n.leases.Release(context.Background(), getGameOwnerKey(gameId), n.Id); err != nil {
+			slog.Error("Error releasing game", "gameId", gameId, "error", err)
+		}
+	}
+}
+
+// Stops the node as if it had crashed, keeping its leases until they run out.
+func (n *Node) Kill() {
+	n.shutdown()
+}
+
+// Stops the timers and waits for those that already fired, returning the games the node owned.
+// Only the first call does anything.
+func (n *Node) shutdown() []string {
+	var gameIds []string
+	n.stopOnce.Do(func() {
+		eventListener.Unsubscribe(getNodeChannelName(n.Id), n.Id)
+
+		n.lock.Lock()
+		close(n.done)
+		for gameId := range n.timers {
+			n.stopTimerLocked(gameId)
+			gameIds = append(gameIds, gameId)
+		}
+		n.lock.Unlock()
+
+		n.firing.Wait()
+	})
+	return gameIds
+}
+
+// Stops the game's timer, the caller holds the lock.
+func (n *Node) stopTimerLocked(gameId string) {
+	timer, ok := n.timers[gameId]
+	if !ok {
+		return
+	}
+	// A timer that already fired marks itself done
+	if timer.Stop() {
+		n.firing.Done()
+	}
+	delete(n.timers, gameId)
+}
+
+// Returns the games whose timers run on this node.
+func (n *Node) OwnedGames() []string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	gameIds := make([]string, 0, len(n.timers))
+	for gameId := range n.timers {
+		gameIds = append(gameIds, gameId)
+	}
+	return gameIds
+}
+
+// Saves the deadline of the turn being drawn and has the game's owner end the turn when it passes.
+func (n *Node) ScheduleTurnTimeout(gameId string, drawerId string, deadline time.Time) {
+	rdb := eventListener.GetPubSub()
+	err := rdb.HSet(context.Background(), getTurnDeadlineKey(gameId), map[string]any{
+		"deadline": deadline.UTC().Format(time.RFC3339Nano),
+		"drawer":   drawerId,
+	}).Err()
+	if err != nil {
+		slog.Error("Error saving turn deadline", "gameId", gameId, "error", err)
+	}
+	rdb.ExpireAt(context.Background(), getTurnDeadlineKey(gameId), deadline.Add(DRAWING_TTL))
+
+	n.drive(gameId)
+}
+
+// Makes sure the owner of the game runs a timer for its deadline, taking ownership if the game
+// has no owner.
+func (n *Node) drive(gameId string) {
+	ctx := context.Background()
+	owned, err := n.leases.Acquire(ctx, getGameOwnerKey(gameId), n.Id)
+	if err != nil {
+		slog.Error("Error acquiring game", "gameId", gameId, "error", err)
+		return
+	}
+	if owned {
+		n.armFromStore(gameId)
+		return
+	}
+
+	// If the owner died the game is adopted once its lease runs out
+	owner, err := n.leases.Holder(ctx, getGameOwnerKey(gameId))
+	if err != nil || owner == "" {
+		return
+	}
+	if err := eventListener.GetPubSub().Publish(ctx, getNodeChannelName(owner), gameId).Err(); err != nil {
+		slog.Error("Error handing game to its owner", "gameId", gameId, "nodeId", owner, "error", err)
+	}
+}
+
+// Arms the timer for the deadline saved in redis, giving the game up if its turn already ended.
+func (n *Node) armFromStore(gameId string) {
+	saved, err := eventListener.GetPubSub().HGetAll(context.Background(), getTurnDeadlineKey(gameId)).Result()
+	if err != nil {
+		slog.Error("Error reading turn deadline", "gameId", gameId, "error", err)
+		return
+	}
+	if len(saved) == 0 {
+		n.stopTimer(gameId)
+		return
+	}
+
+	deadline, err := time.Parse(time.RFC3339Nano, saved["deadline"])
+	if err != nil {
+		slog.Error("Invalid turn deadline", "gameId", gameId, "error", err)
+		return
+	}
+	n.arm(gameId, saved["deadline"], deadline)
+}
+
+func (n *Node) arm(gameId string, savedDeadline string, deadline time.Time) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	select {
+	case <-n.done:
+		return
+	default:
+	}
+	n.stopTimerLocked(gameId)
+	n.firing.Add(1)
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(deadline), func() {
+		defer n.firing.Done()
+		n.lock.Lock()
+		current := n.timers[gameId] == timer
+		if current {
+			delete(n.timers, gameId)
+		}
+		n.lock.Unlock()
+
+		if current {
+			n.fire(gameId, savedDeadline)
+		}
+	})
+	n.timers[gameId] = timer
+}
+
+// Ends the turn if this node still owns the game and the deadline hasn't changed since the timer
+// was armed.
+func (n *Node) fire(gameId string, savedDeadline string) {
+	ctx := context.Background()
+	ownerKey := getGameOwnerKey(gameId)
+	owned, err := n.leases.Renew(ctx, ownerKey, n.Id)
+	if err != nil || !owned {
+		return
+	}
+
+	drawerId, claimed, err := claimTurnDeadline(gameId, savedDeadline)
+	n.leases.Release(ctx, ownerKey, n.Id)
+	if err != nil {
+		slog.Error("Error claiming turn deadline", "gameId", gameId, "error", err)
+		return
+	}
+	if claimed {
+		n.onTimeout(gameId, drawerId)
+	}
+}
+
+// Stops the game's timer on this node and gives the game up.
+func (n *Node) stopTimer(gameId string) {
+	n.lock.Lock()
+	n.stopTimerLocked(gameId)
+	n.lock.Unlock()
+
+	n.leases.Release(context.Background(), getGameOwnerKey(gameId), n.Id)
+}
+
+// Renews the leases of owned games and adopts orphaned ones until the node stops. Leases are
+// renewed three times per lease so a slow round trip doesn't lose them.
+func (n *Node) maintain() {
+	ticker := time.NewTicker(n.leases.TTL() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, gameId := range n.OwnedGames() {
+			owned, err := n.leases.Renew(context.Background(), getGameOwnerKey(gameId), n.Id)
+			if err != nil {
+				slog.Error("Error renewing game lease", "gameId", gameId, "error", err)
+				continue
+			}
+			if !owned {
+				slog.Warn("lost game to another node", "gameId", gameId, "nodeId", n.Id)
+				n.lock.Lock()
+				n.stopTimerLocked(gameId)
+				n.lock.Unlock()
+			}
+		}
+		if _, err := n.adoptOrphans(); err != nil {
+			slog.Error("Error adopting games", "nodeId", n.Id, "error", err)
+		}
+	}
+}
+
+// Takes over the games with a deadline and no owner, returning how many it took.
+func (n *Node) adoptOrphans() (int, error) {
+	ctx := context.Background()
+	rdb := eventListener.GetPubSub()
+
+	adopted := 0
+	iter := rdb.Scan(ctx, 0, getTurnDeadlineKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		gameId, ok := strings.CutPrefix(strings.TrimSuffix(iter.Val(), "/turn_deadline"), "game/")
+		if !ok {
+			continue
+		}
+		owner, err := n.leases.Holder(ctx, getGameOwnerKey(gameId))
+		if err != nil {
+			return adopted, err
+		}
+		if owner != "" {
+			continue
+		}
+		owned, err := n.leases.Acquire(ctx, getGameOwnerKey(gameId), n.Id)
+		if err != nil {
+			return adopted, err
+		}
+		if owned {
+			n.armFromStore(gameId)
+			adopted++
+		}
+	}
+	return adopted, iter.Err()
+}
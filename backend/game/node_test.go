@@ -0,0 +1,155 @@
+package game
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"scribl-clone/config"
+	"scribl-clone/eventListener"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+)
+
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	testRedis = mr
+	eventListener.Connect(config.RedisConfig{Addr: mr.Addr()})
+
+	code := m.Run()
+	eventListener.Close()
+	mr.Close()
+	os.Exit(code)
+}
+
+// miniredis only expires keys when told time has passed, so leases of crashed nodes run out.
+func advanceRedisClock(t *testing.T) {
+	const step = 20 * time.Millisecond
+	ticker := time.NewTicker(step)
+	done := make(chan struct{})
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				testRedis.FastForward(step)
+			}
+		}
+	}()
+	t.Cleanup(func() { close(done) })
+}
+
+// Runs several nodes against the same redis, crashing one, and checks that every turn ends exactly
+// once, including the turns the crashed node owned.
+func TestTurnsEndOnceAcrossNodes(t *testing.T) {
+	t.Cleanup(testRedis.FlushAll)
+	const (
+		nodeCount = 3
+		gameCount = 30
+		leaseTTL  = 300 * time.Millisecond
+		turn      = 500 * time.Millisecond
+	)
+	advanceRedisClock(t)
+
+	var lock sync.Mutex
+	endedBy := map[string][]string{}
+
+	nodes := make([]*Node, nodeCount)
+	for i := range nodes {
+		nodeId := fmt.Sprintf("%s-node-%d", t.Name(), i)
+		nodes[i] = NewNode(nodeId, NodeOptions{
+			LeaseTTL: leaseTTL,
+			OnTimeout: func(gameId string, drawerId string) {
+				lock.Lock()
+				endedBy[gameId] = append(endedBy[gameId], nodeId)
+				lock.Unlock()
+			},
+		})
+		if err := nodes[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Turns are started through random nodes, as requests would land on random replicas
+	gameIds := make([]string, gameCount)
+	for i := range gameIds {
+		gameIds[i] = uuid.NewString()
+		deadline := time.Now().Add(turn + time.Duration(rand.Int63n(int64(turn))))
+		nodes[rand.Intn(len(nodes))].ScheduleTurnTimeout(gameIds[i], "drawer", deadline)
+	}
+
+	crashed := nodes[0]
+	if len(crashed.OwnedGames()) == 0 {
+		t.Log("the crashed node owned no games")
+	}
+	crashed.Kill()
+
+	// The crashed node's games are adopted within a lease and a renewal interval of it dying
+	time.Sleep(2*turn + 3*leaseTTL)
+	for _, node := range nodes[1:] {
+		node.Stop()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, gameId := range gameIds {
+		if ended := endedBy[gameId]; len(ended) != 1 {
+			t.Errorf("turn of game %s ended %d times, by %v", gameId, len(ended), ended)
+		}
+	}
+}
+
+func TestStopWaitsForTimersThatFired(t *testing.T) {
+	t.Cleanup(testRedis.FlushAll)
+	var finished atomic.Bool
+	fired := make(chan struct{})
+	node := NewNode(t.Name(), NodeOptions{
+		OnTimeout: func(string, string) {
+			close(fired)
+			time.Sleep(100 * time.Millisecond)
+			finished.Store(true)
+		},
+	})
+	if err := node.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	node.ScheduleTurnTimeout(uuid.NewString(), "drawer", time.Now())
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the timer didn't fire")
+	}
+	node.Stop()
+	if !finished.Load() {
+		t.Error("Stop returned while the timer was still running")
+	}
+}
+
+func TestStopAndKillCanBeRepeated(t *testing.T) {
+	t.Cleanup(testRedis.FlushAll)
+	node := NewNode(t.Name(), NodeOptions{OnTimeout: func(string, string) {}})
+	if err := node.Start(); err != nil {
+		t.Fatal(err)
+	}
+	node.ScheduleTurnTimeout(uuid.NewString(), "drawer", time.Now().Add(time.Hour))
+
+	node.Stop()
+	node.Stop()
+	node.Kill()
+	if owned := node.OwnedGames(); len(owned) != 0 {
+		t.Errorf("stopped node still owns %v", owned)
+	}
+}
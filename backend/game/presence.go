@@ -0,0 +1,22 @@
+package game
+
+import "scribl-clone/data"
+
+// Marks an active player as disconnected once their last connection is gone, so the others can
+// see they are away.
+func MarkDisconnected(gameId string, playerId string) error {
+	return transitionPresence(gameId, playerId, data.PLAYER_STATE_ACTIVE, data.PLAYER_STATE_DISCONNECTED)
+}
+
+// Marks a disconnected player as active again when they reconnect.
+func MarkConnected(gameId string, playerId string) error {
+	return transitionPresence(gameId, playerId, data.PLAYER_STATE_DISCONNECTED, data.PLAYER_STATE_ACTIVE)
+}
+
+func transitionPresence(gameId string, playerId string, from string, to string) error {
+	changed, err := data.TransitionPlayerState(playerId, from, to)
+	if err != nil || !changed {
+		return err
+	}
+	return UpdatePlayer(gameId, playerId, map[string]any{"ActiveState": to})
+}
@@ -0,0 +1,9 @@
+package game
+
+import "scribl-clone/data"
+
+// Marks the player as active once their client has loaded the game. Only players still being
+// created are changed, so a kicked player can't make themselves active again.
+func MarkReady(gameId string, playerId string) error {
+	return transitionPresence(gameId, playerId, data.PLAYER_STATE_CREATING, data.PLAYER_STATE_ACTIVE)
+}
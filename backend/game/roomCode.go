@@ -0,0 +1,150 @@
+package game
+
+// This module gives games short codes players can read out to each other. Codes leave out
+// characters that are easily confused, like 0 and O, 1, I and L, or 5 and S, and are matched
+// case-insensitively.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"scribl-clone/eventListener"
+	"scribl-clone/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ROOM_CODE_ALPHABET = "ABCDEFGHJKMNPQRSTUVWXYZ34679"
+	ROOM_CODE_LENGTH   = 6
+	// A code is released once its game has seen no activity for this long
+	ROOM_CODE_TTL = 24 * time.Hour
+	// Attempts at finding a free code before giving up
+	ROOM_CODE_ATTEMPTS = 10
+)
+
+var ErrNoFreeRoomCode = errors.New("could not find a free room code")
+
+func getRoomKey(code string) string {
+	return fmt.Sprintf("room/%s", code)
+}
+
+func getRoomCodeKey(gameId string) string {
+	return fmt.Sprintf("game/%s/room_code", gameId)
+}
+
+// Reserves the code and records it as the game's in one step, so a failure can't leave a code
+// reserved for a game that doesn't know it.
+var reserveRoomCodeScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[3]) then
+	redis.call("SET", KEYS[2], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// Reserves an unused code for the game.
+func AssignRoomCode(gameId string) (string, error) {
+	rdb := eventListener.GetPubSub()
+	ctx := context.Background()
+
+	for range ROOM_CODE_ATTEMPTS {
+		code := utils.RandStringFrom(ROOM_CODE_ALPHABET, ROOM_CODE_LENGTH)
+		keys := []string{getRoomKey(code), getRoomCodeKey(gameId)}
+		reserved, err := reserveRoomCodeScript.Run(ctx, rdb, keys, gameId, code, ROOM_CODE_TTL.Milliseconds()).Int()
+		if err != nil {
+			return "", err
+		}
+		if reserved == 1 {
+			return code, nil
+		}
+	}
+	return "", ErrNoFreeRoomCode
+}
+
+// Returns the game's code, or "" if it has none or it was released.
+func GetRoomCode(gameId string) (string, error) {
+	code, err := eventListener.GetPubSub().Get(context.Background(), getRoomCodeKey(gameId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return code, err
+}
+
+// Returns the id of the game a code belongs to.
+func ResolveRoomCode(code string) (string, error) {
+	code = NormaliseRoomCode(code)
+	if code == "" {
+		return "", utils.ErrResourceNotFound
+	}
+	gameId, err := eventListener.GetPubSub().Get(context.Background(), getRoomKey(code)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", utils.ErrResourceNotFound
+	}
+	return gameId, err
+}
+
+// Accepts either a game id or a room code, returning the game id.
+func ResolveGameId(idOrCode string) (string, error) {
+	if uuid.Validate(idOrCode) == nil {
+		return idOrCode, nil
+	}
+	return ResolveRoomCode(idOrCode)
+}
+
+// Keeps the game's code reserved for another ROOM_CODE_TTL, called when the game sees activity.
+func RefreshRoomCode(gameId string) error {
+	code, err := GetRoomCode(gameId)
+	if err != nil || code == "" {
+		return err
+	}
+	rdb := eventListener.GetPubSub()
+	ctx := context.Background()
+	if err := rdb.Expire(ctx, getRoomKey(code), ROOM_CODE_TTL).Err(); err != nil {
+		return err
+	}
+	return rdb.Expire(ctx, getRoomCodeKey(gameId), ROOM_CODE_TTL).Err()
+}
+
+// Digits that were left out of the alphabet for looking like one of its letters
+var roomCodeLookAlikes = strings.NewReplacer("2", "Z", "5", "S", "8", "B")
+
+// Upper cases a code typed by a player, returning "" if it can't be a code. Digits that look like
+// a letter of the alphabet are read as that letter.
+func NormaliseRoomCode(code string) string {
+	code = roomCodeLookAlikes.Replace(strings.ToUpper(strings.TrimSpace(code)))
+	if len(code) != ROOM_CODE_LENGTH {
+		return ""
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(ROOM_CODE_ALPHABET, c) {
+			return ""
+		}
+	}
+	return code
+}
+
+// Returns the codes of several games at once, leaving out games that have none.
+func GetRoomCodes(gameIds []string) (map[string]string, error) {
+	codes := make(map[string]string, len(gameIds))
+	if len(gameIds) == 0 {
+		return codes, nil
+	}
+	keys := make([]string, len(gameIds))
+	for i, gameId := range gameIds {
+		keys[i] = getRoomCodeKey(gameId)
+	}
+	values, err := eventListener.GetPubSub().MGet(context.Background(), keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if code, ok := value.(string); ok {
+			codes[gameIds[i]] = code
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/moderation"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TURN_DURATION = 30 * time.Second
+	// Words offered to a drawer who never picks one are forgotten after this long
+	WORD_CHOICES_TTL = 24 * time.Hour
+)
+
+func getWordChoicesKey(gameId string) string {
+	return fmt.Sprintf("game/%s/word_choices", gameId)
+}
+
+// Remembers the words the drawer was offered, replacing the previous turn's.
+func storeWordChoices(gameId string, choices []string) error {
+	key := getWordChoicesKey(gameId)
+	members := make([]any, len(choices))
+	for i, choice := range choices {
+		members[i] = choice
+	}
+	_, err := eventListener.GetPubSub().TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.Del(context.Background(), key)
+		pipe.SAdd(context.Background(), key, members...)
+		pipe.Expire(context.Background(), key, WORD_CHOICES_TTL)
+		return nil
+	})
+	return err
+}
+
+// Reports whether the word is one of those offered to the drawer.
+func isWordChoice(gameId string, word string) (bool, error) {
+	return eventListener.GetPubSub().SIsMember(context.Background(), getWordChoicesKey(gameId), word).Result()
+}
+
+func clearWordChoices(gameId string) error {
+	return eventListener.GetPubSub().Del(context.Background(), getWordChoicesKey(gameId)).Err()
+}
+
+// Sets the word the drawer chose from the ones they were offered and starts the drawing. The drawer
+// gets points and the game moves on to the next turn if the word hasn't been guessed by everyone
+// within TURN_DURATION.
+func ChooseWord(gameId string, drawerId string, word string) error {
+	db := data.GetDb()
+
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT id, turn, state FROM game WHERE id = $1`, gameId); err != nil {
+		return err
+	}
+
+	if !g.Turn.Valid || g.Turn.String != drawerId {
+		return ErrNotYourTurn
+	}
+
+	if g.State != data.GAME_STATE_SELECTING_WORD {
+		return ErrNotSelectingWord
+	}
+
+	// Word packs can hold custom words, which are shown to everyone once the turn is over, so they
+	// must get through the filter unchanged
+	if moderated, err := moderation.Apply(word); err != nil || moderated != word {
+		return moderation.ErrRejected
+	}
+
+	offered, err := isWordChoice(gameId, word)
+	if err != nil {
+		return err
+	}
+	if !offered {
+		return ErrNotOffered
+	}
+
+	// The drawer may have chosen on another node in the meantime
+	result, err := db.Exec(
+		`UPDATE game SET word = $2, state = $3 WHERE id = $1 AND turn = $4 AND state = $5;`,
+		g.Id,
+		word,
+		data.GAME_STATE_DRAWING,
+		drawerId,
+		data.GAME_STATE_SELECTING_WORD,
+	)
+	if err != nil {
+		return err
+	}
+	chosen, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if chosen == 0 {
+		return ErrNotSelectingWord
+	}
+	if err := clearWordChoices(gameId); err != nil {
+		slog.Error("Error clearing word choices", "gameId", gameId, "error", err)
+	}
+
+	SelectWord(gameId, word)
+
+	scheduleTurnTimeout(gameId, drawerId, time.Now().Add(TURN_DURATION))
+	return nil
+}
+
+// Validates that the player is the one drawing before broadcasting their line. The line is
+// clamped to the bounds in drawing.go.
+func Draw(gameId string, playerId string, line Line, lineIndex int) error {
+	if lineIndex < 0 || lineIndex >= MAX_LINES_PER_TURN {
+		return ErrInvalidLine
+	}
+	db := data.GetDb()
+
+	g := data.Game{}
+	if err := db.Get(&g, `SELECT state, turn FROM game WHERE id = $1`, gameId); err != nil {
+		return err
+	}
+	if g.State != data.GAME_STATE_DRAWING {
+		return ErrWrongState
+	}
+	if g.Turn.String != playerId {
+		return ErrNotYourTurn
+	}
+	return UpsertLine(gameId, clampLine(line), lineIndex)
+}
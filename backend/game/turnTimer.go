@@ -0,0 +1,116 @@
+package game
+
+// This module ends turns when the drawer runs out of time. Deadlines are kept in redis, and the
+// node that owns the game runs the timer, see node.go.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/metrics"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func getTurnDeadlineKey(gameId string) string {
+	return fmt.Sprintf("game/%s/turn_deadline", gameId)
+}
+
+func scheduleTurnTimeout(gameId string, drawerId string, deadline time.Time) {
+	DefaultNode().ScheduleTurnTimeout(gameId, drawerId, deadline)
+}
+
+// Stops the game's timer once its turn has ended some other way. A timer running on another node
+// finds the deadline gone when it fires and does nothing.
+func stopTurnTimer(gameId string) {
+	DefaultNode().stopTimer(gameId)
+	eventListener.GetPubSub().Del(context.Background(), getTurnDeadlineKey(gameId))
+}
+
+// Takes the deadline out of redis if it is still the one a timer was armed for, returning the
+// drawer. Only one node can claim a deadline, so a turn is never ended twice.
+var claimDeadlineScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "deadline") == ARGV[1] then
+	local drawer = redis.call("HGET", KEYS[1], "drawer")
+	redis.call("DEL", KEYS[1])
+	return drawer
+end
+return false
+`)
+
+func claimTurnDeadline(gameId string, deadline string) (string, bool, error) {
+	drawerId, err := claimDeadlineScript.Run(context.Background(), eventListener.GetPubSub(), []string{getTurnDeadlineKey(gameId)}, deadline).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	return drawerId, err == nil, err
+}
+
+// Records how long the turn lasted, working back from its deadline.
+func observeTurnDuration(gameId string) {
+	deadline, err := eventListener.GetPubSub().HGet(context.Background(), getTurnDeadlineKey(gameId), "deadline").Result()
+	if err != nil {
+		return
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, deadline)
+	if err != nil {
+		return
+	}
+	metrics.TurnDuration.Observe((TURN_DURATION - time.Until(parsed)).Seconds())
+}
+
+// Stops the drawer's turn, reporting false if it had already ended. The last correct guess and
+// the timer can end a turn at the same time on different nodes, only the caller that ends it goes
+// on to the next turn.
+func endDrawing(gameId string, drawerId string) (bool, error) {
+	result, err := data.GetDb().Exec(
+		`UPDATE game SET state = $4 WHERE id = $1 AND turn = $2 AND state = $3;`,
+		gameId,
+		drawerId,
+		data.GAME_STATE_DRAWING,
+		data.GAME_STATE_SELECTING_WORD,
+	)
+	if err != nil {
+		return false, err
+	}
+	ended, err := result.RowsAffected()
+	return ended > 0, err
+}
+
+func endTurnOnTimeout(gameId string, drawerId string) {
+	db := data.GetDb()
+
+	ended, err := endDrawing(gameId, drawerId)
+	if err != nil {
+		slog.Error(err.Error(), "gameId", gameId)
+		return
+	}
+	if !ended {
+		return
+	}
+	metrics.TurnDuration.Observe(TURN_DURATION.Seconds())
+
+	var drawerScore int
+	err = db.Get(&drawerScore, `
+		UPDATE player SET score = score + $1
+			WHERE id = $2
+			RETURNING score
+		`,
+		POINTS_DRAWER_TIMEOUT,
+		drawerId,
+	)
+
+	if err != nil {
+		slog.Error(err.Error(), "gameId", gameId)
+		return
+	}
+
+	ScoreUpdate(gameId, map[string]int{drawerId: drawerScore})
+	if err := GotoNextTurn(gameId, drawerId); err != nil {
+		slog.Error(err.Error(), "gameId", gameId)
+	}
+}
@@ -0,0 +1,90 @@
+package guess
+
+import "testing"
+
+func TestNormalise(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"already normal", "cat", "cat"},
+		{"upper case", "CaT", "cat"},
+		{"accents", "Crème Brûlée", "creme brulee"},
+		{"letters that don't decompose", "Straße øl", "strasse ol"},
+		{"punctuation becomes spaces", "ice-cream!", "ice cream"},
+		{"whitespace is collapsed", "  hot \t dog  ", "hot dog"},
+		{"only punctuation", "?!", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalise(tt.text); got != tt.want {
+				t.Errorf("Normalise(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"cat", "cat", 0},
+		{"", "cat", 3},
+		{"cat", "cats", 1},
+		{"cat", "cut", 1},
+		{"cat", "act", 1},
+		{"ca", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := editDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := editDistance(tt.b, tt.a); got != tt.want {
+				t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		guess   string
+		word    string
+		aliases []string
+		want    Result
+	}{
+		{"exact", "apple", "apple", nil, RESULT_CORRECT},
+		{"case and accents", "CRÈME brûlée", "creme brulee", nil, RESULT_CORRECT},
+		{"spaces are ignored", "icecream", "ice cream", nil, RESULT_CORRECT},
+		{"punctuation is ignored", "ice-cream!", "ice cream", nil, RESULT_CORRECT},
+		{"empty guess", "", "apple", nil, RESULT_WRONG},
+		{"only punctuation", "!!!", "apple", nil, RESULT_WRONG},
+		{"unrelated", "banana", "apple", nil, RESULT_WRONG},
+
+		// Short words allow one edit, longer words two
+		{"short word one edit", "appel", "apple", nil, RESULT_CLOSE},
+		{"short word two edits", "aplpx", "apple", nil, RESULT_WRONG},
+		{"six letter word two edits", "aplee", "apples", nil, RESULT_CLOSE},
+		{"long word one swap and one typo", "elpehanr", "elephant", nil, RESULT_CLOSE},
+		{"phonetic spelling", "elefent", "elephant", nil, RESULT_WRONG},
+		{"long word three edits", "elxphxnx", "elephant", nil, RESULT_WRONG},
+
+		{"alias", "kitty", "cat", []string{"kitten", "kitty"}, RESULT_CORRECT},
+		{"close to an alias", "kiten", "cat", []string{"kitten"}, RESULT_CLOSE},
+		{"correct beats close", "cat", "cat", []string{"cats"}, RESULT_CORRECT},
+		{"empty alias is skipped", "", "cat", []string{""}, RESULT_WRONG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Match(tt.guess, tt.word, tt.aliases); got != tt.want {
+				t.Errorf("Match(%q, %q, %v) = %v, want %v", tt.guess, tt.word, tt.aliases, got, tt.want)
+			}
+		})
+	}
+}
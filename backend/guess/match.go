@@ -0,0 +1,64 @@
+package guess
+
+// This module decides whether a guess matches the word being drawn
+
+import "unicode/utf8"
+
+type Result int
+
+const (
+	RESULT_WRONG   Result = 0
+	RESULT_CLOSE   Result = 1
+	RESULT_CORRECT Result = 2
+)
+
+// Words up to this length only count a single mistake as close, longer words allow two.
+const SHORT_WORD_LENGTH = 5
+
+func (r Result) String() string {
+	switch r {
+	case RESULT_CLOSE:
+		return "close"
+	case RESULT_CORRECT:
+		return "correct"
+	default:
+		return "wrong"
+	}
+}
+
+func (r Result) IsCorrect() bool {
+	return r == RESULT_CORRECT
+}
+
+// Compares a guess against the word and its aliases once both are normalised. Spaces are ignored
+// so "ice cream" and "icecream" match. A guess that is a small number of edits away from any of
+// the answers is close.
+func Match(guess string, word string, aliases []string) Result {
+	normalisedGuess := removeSpaces(Normalise(guess))
+	if normalisedGuess == "" {
+		return RESULT_WRONG
+	}
+
+	result := RESULT_WRONG
+	for _, answer := range append([]string{word}, aliases...) {
+		normalisedAnswer := removeSpaces(Normalise(answer))
+		if normalisedAnswer == "" {
+			continue
+		}
+		if normalisedGuess == normalisedAnswer {
+			return RESULT_CORRECT
+		}
+		if isClose(normalisedGuess, normalisedAnswer) {
+			result = RESULT_CLOSE
+		}
+	}
+	return result
+}
+
+func isClose(guess string, answer string) bool {
+	maxDistance := 2
+	if utf8.RuneCountInString(answer) <= SHORT_WORD_LENGTH {
+		maxDistance = 1
+	}
+	return editDistance(guess, answer) <= maxDistance
+}
@@ -0,0 +1,62 @@
+package guess
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters that don't decompose into a base letter and combining marks, so NFD leaves them alone.
+var diacritics = map[string]string{
+	"d":  "đð",
+	"h":  "ħ",
+	"i":  "ı",
+	"l":  "łŀ",
+	"n":  "ŉ",
+	"o":  "ø",
+	"t":  "ŧ",
+	"ss": "ß",
+	"ae": "æ",
+	"oe": "œ",
+	"th": "þ",
+}
+
+var foldedRunes = buildFoldedRunes()
+
+func buildFoldedRunes() map[rune]string {
+	folded := make(map[rune]string)
+	for base, runes := range diacritics {
+		for _, r := range runes {
+			folded[r] = base
+		}
+	}
+	return folded
+}
+
+// Lower cases the text, strips diacritics, turns punctuation into spaces and collapses runs of
+// whitespace, so "  Crème-Brûlée! " becomes "creme brulee".
+func Normalise(text string) string {
+	var b strings.Builder
+	// Decomposing splits accented letters into their base letter and combining marks, which are
+	// then dropped
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		if folded, ok := foldedRunes[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Accents and other combining marks
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func removeSpaces(text string) string {
+	return strings.ReplaceAll(text, " ", "")
+}
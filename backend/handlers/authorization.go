@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+)
+
+// Checks the request carries a token for a player of the game.
+func authorizeGameMember(r *http.Request, gameId string) (player.PlayerClaim, error) {
+	claim, err := player.AuthorizeRequest(r)
+	if err != nil {
+		return claim, err
+	}
+	if claim.GameId != gameId {
+		return claim, utils.ErrForbidden
+	}
+	return claim, nil
+}
+
+// Fetches a player the requester is allowed to see, which is any player of the same game. Players
+// of other games are reported as not found so their ids can't be probed.
+func getVisiblePlayer(r *http.Request, playerId string) (*data.Player, error) {
+	claim, err := player.AuthorizeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := data.GetPlayer(playerId)
+	if err != nil {
+		return nil, err
+	}
+	if p.Game != claim.GameId {
+		return nil, utils.ErrResourceNotFound
+	}
+	return p, nil
+}
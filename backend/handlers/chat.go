@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/guess"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+)
+
+func SendChat(w http.ResponseWriter, r *http.Request) {
+	claim, err := player.AuthorizeRequest(r)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if claim.GameId != chi.URLParam(r, "gameId") {
+		utils.HandleError(w, r, player.ErrUnauthorized)
+		return
+	}
+
+	body := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := game.SendChat(claim.GameId, claim.PlayerId, body.Message)
+	if err != nil {
+		handleGameError(w, r, err)
+		return
+	}
+
+	if result == guess.RESULT_CLOSE {
+		w.Write(CLOSE_GUESS_RESPONSE)
+		return
+	}
+	w.Write(utils.STANDARD_SUCCESS_RESPONSE)
+}
+
+func GetChat(w http.ResponseWriter, r *http.Request) {
+	claim, err := player.AuthorizeRequest(r)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if claim.GameId != chi.URLParam(r, "gameId") {
+		utils.HandleError(w, r, player.ErrUnauthorized)
+		return
+	}
+
+	messages, err := game.GetChatHistory(claim.GameId, claim.PlayerId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(messages)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
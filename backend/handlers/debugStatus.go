@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/game"
+	"scribl-clone/player"
+	"scribl-clone/sockets"
+	"scribl-clone/utils"
+	"strconv"
+)
+
+type DebugStatusPayload struct {
+	// Games that haven't ended, by state
+	ActiveGames map[string]int `json:"activeGames"`
+	// Open websockets on this instance, by game
+	Sockets map[string]int `json:"sockets"`
+	// Clients of each redis subscription on this instance, by channel
+	Subscriptions map[string]int `json:"subscriptions"`
+	NodeId        string         `json:"nodeId"`
+	// Games whose turn timers run on this instance
+	OwnedGames []string `json:"ownedGames"`
+}
+
+// Returns a handler describing the state of this instance. Requests must carry the configured
+// token as a bearer token, and the endpoint doesn't exist when no token is configured.
+func DebugStatus(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			utils.HandleError(w, r, utils.ErrResourceNotFound)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(player.GetBearerToken(r)), []byte(token)) != 1 {
+			utils.HandleError(w, r, player.ErrUnauthorized)
+			return
+		}
+
+		games, err := data.CountActiveGames()
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		status := DebugStatusPayload{
+			ActiveGames:   make(map[string]int, len(games)),
+			Sockets:       map[string]int{},
+			Subscriptions: eventListener.SubscriptionCounts(),
+			NodeId:        game.DefaultNode().Id,
+			OwnedGames:    game.DefaultNode().OwnedGames(),
+		}
+		for state, count := range games {
+			status.ActiveGames[strconv.Itoa(state)] = count
+		}
+		for channel, count := range sockets.ConnectionCounts() {
+			if gameId, ok := game.ParseGameChannelName(channel); ok {
+				status.Sockets[gameId] += count
+			}
+		}
+
+		payload, err := json.Marshal(status)
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		w.Write(payload)
+	}
+}
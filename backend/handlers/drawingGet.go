@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/renderer"
+	"scribl-clone/utils"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+const MAX_DRAWING_SIZE = 2048
+
+func GetTurnDrawing(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+	format := chi.URLParam(r, "format")
+
+	turn, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil {
+		http.Error(w, "Invalid turn", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseDrawingOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lines, err := game.GetLines(gameId, turn)
+	if err != nil {
+		if errors.Is(err, game.ErrTurnNotFound) {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	switch format {
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+		err = renderer.RenderPNG(w, lines, opts)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		err = renderer.RenderSVG(w, lines, opts)
+	default:
+		http.Error(w, http.StatusText(404), 404)
+		return
+	}
+	if err != nil {
+		utils.HandleError(w, r, err)
+	}
+}
+
+// Reads the optional width and height query parameters, defaulting to the size of the canvas.
+func parseDrawingOptions(r *http.Request) (renderer.Options, error) {
+	opts := renderer.DefaultOptions()
+	for param, value := range map[string]*int{"width": &opts.Width, "height": &opts.Height} {
+		raw := r.URL.Query().Get(param)
+		if raw == "" {
+			continue
+		}
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > MAX_DRAWING_SIZE {
+			return opts, errors.New("invalid " + param)
+		}
+		*value = size
+	}
+	return opts, nil
+}
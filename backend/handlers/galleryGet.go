@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/renderer"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+)
+
+const THUMBNAIL_SIZE = 160
+
+type galleryDrawing struct {
+	data.Turn
+	Thumbnail string `json:"thumbnail"`
+	Png       string `json:"png"`
+	Svg       string `json:"svg"`
+}
+
+// Lists the drawings of every finished turn of a game, with an inline thumbnail of each. Finished
+// turns don't change, so each thumbnail is rendered once and cached.
+func GetGallery(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	g, err := data.GetGame(gameId)
+	if err != nil || g == nil {
+		http.Error(w, http.StatusText(404), 404)
+		return
+	}
+
+	turns, err := data.GetTurns(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	drawings := make([]galleryDrawing, 0, len(turns))
+	for _, turn := range turns {
+		thumbnail, err := getThumbnail(r, turn)
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		drawingPath := fmt.Sprintf("/game/%s/turns/%d/drawing", gameId, turn.Number)
+		drawings = append(drawings, galleryDrawing{
+			Turn:      turn,
+			Thumbnail: thumbnail,
+			Png:       drawingPath + ".png",
+			Svg:       drawingPath + ".svg",
+		})
+	}
+
+	payload, err := json.Marshal(drawings)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
+
+// Returns the thumbnail of a turn as a data URL.
+func getThumbnail(r *http.Request, turn data.Turn) (string, error) {
+	opts := renderer.Options{Width: THUMBNAIL_SIZE, Height: THUMBNAIL_SIZE}
+
+	encoded, ok, err := renderer.CachedThumbnail(turn.Game, turn.Number, opts)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading cached thumbnail", "error", err)
+	}
+	if !ok {
+		if encoded, err = renderThumbnail(turn, opts); err != nil {
+			return "", err
+		}
+		if err := renderer.CacheThumbnail(turn.Game, turn.Number, opts, encoded); err != nil {
+			slog.ErrorContext(r.Context(), "Error caching thumbnail", "error", err)
+		}
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded), nil
+}
+
+func renderThumbnail(turn data.Turn, opts renderer.Options) ([]byte, error) {
+	lines := []game.Line{}
+	if err := json.Unmarshal(turn.Lines, &lines); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := renderer.RenderPNG(&b, lines, opts); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
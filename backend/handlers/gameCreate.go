@@ -2,23 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"scribl-clone/data"
+	"scribl-clone/game"
 	"scribl-clone/utils"
 )
 
-const ID_LENGTH = 6
+// A game as returned to clients, along with the code players join it by.
+type gameWithRoomCode struct {
+	data.PublicGame
+	RoomCode string `json:"roomCode"`
+}
 
+// Creates a game. The body can hold the game's settings, any left out get their defaults.
 func CreateGame(w http.ResponseWriter, r *http.Request) {
-	game, err := data.CreateNewGame()
+	settings := data.GameSettings{}
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g, code, err := game.NewGame(settings)
 	if err != nil {
-		utils.HandleError(w, err)
+		handleGameError(w, r, err)
 		return
 	}
 
-	payload, err := json.Marshal(game)
+	payload, err := json.Marshal(gameWithRoomCode{PublicGame: g.WithoutWord(), RoomCode: code})
 	if err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 	w.Write(payload)
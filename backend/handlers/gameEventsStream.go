@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/game"
+	"scribl-clone/player"
+	"scribl-clone/replay"
+	"scribl-clone/sockets"
+	"scribl-clone/utils"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+const (
+	// Events waiting to be written to a stream. A client that falls further behind is disconnected
+	// and catches up from the recording when it reconnects.
+	EVENT_STREAM_BUFFER = 256
+	// Comments are sent this often so proxies don't close an idle stream
+	EVENT_STREAM_KEEPALIVE = 15 * time.Second
+	// Clients are told to wait this long before reconnecting, in milliseconds
+	EVENT_STREAM_RETRY = 2000
+)
+
+// Streams a game's events as server-sent events, for clients that can't open a websocket. Players
+// send their token, in the token query parameter or the Authorization header, and receive what
+// InitGameConnection would send them, requests without a token are streamed as a spectator when
+// game.OpenToSpectators allows it. Each
+// event carries its sequence number as its id, so a client reconnecting with Last-Event-ID is
+// first sent the events it missed.
+func StreamGameEvents(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	g, err := data.GetGame(gameId)
+	if err != nil || g == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// EventSource can't set headers, so browsers send the token in the query, which is redacted
+	// from the logs
+	token := player.GetRequestToken(r)
+	if token == "" && !game.OpenToSpectators(g) {
+		utils.HandleError(w, r, player.ErrUnauthorized)
+		return
+	}
+	playerId := ""
+	if token != "" {
+		claim, err := player.DecodeToken(token)
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		if claim.GameId != gameId {
+			utils.HandleError(w, r, utils.ErrForbidden)
+			return
+		}
+		p, err := data.GetPlayer(claim.PlayerId)
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		if p.ActiveState == data.PLAYER_STATE_KICKED {
+			utils.HandleError(w, r, utils.ErrForbidden)
+			return
+		}
+		playerId = claim.PlayerId
+	}
+
+	var lastSeq int64
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		if lastSeq, err = strconv.ParseInt(lastEventId, 10, 64); err != nil || lastSeq < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.HandleError(w, r, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	// Subscribing before reading the recording means no event falls in between, events in both
+	// are skipped by their sequence number
+	events := make(chan string, EVENT_STREAM_BUFFER)
+	overflowed := make(chan struct{})
+	subscriptionId := "stream/" + uuid.NewString()
+	channel := game.GetGameChannelName(gameId)
+	eventListener.Subscribe(channel, subscriptionId, func(message string) {
+		select {
+		case events <- message:
+		default:
+			select {
+			case <-overflowed:
+			default:
+				close(overflowed)
+			}
+		}
+	})
+	defer eventListener.Unsubscribe(channel, subscriptionId)
+
+	var missed []replay.Record
+	if lastSeq > 0 {
+		if missed, err = replay.Since(gameId, lastSeq); err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", EVENT_STREAM_RETRY)
+
+	send := func(message string, seq int64) bool {
+		message, ok := game.PrepareForPlayer(message, playerId)
+		if !ok {
+			return true
+		}
+		if seq != 0 {
+			fmt.Fprintf(w, "id: %d\n", seq)
+		}
+		_, err := fmt.Fprintf(w, "data: %s\n\n", message)
+		return err == nil
+	}
+
+	// The recording is numbered from 1, so the first missed event follows the last one seen
+	firstMissed := lastSeq + 1
+	for i, record := range missed {
+		if !send(string(record.Event), firstMissed+int64(i)) {
+			return
+		}
+	}
+	flusher.Flush()
+	// Live events up to here were already seen by the client or sent from the recording. Later
+	// ones are sent as they arrive, even if redis delivers them out of order.
+	backlogSeq := lastSeq + int64(len(missed))
+
+	if playerId != "" {
+		if err := game.MarkConnected(gameId, playerId); err != nil {
+			slog.ErrorContext(r.Context(), "Error marking player connected", "error", err)
+		}
+	}
+
+	keepalive := time.NewTicker(EVENT_STREAM_KEEPALIVE)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			// The player may still be connected through a websocket
+			if playerId != "" && !sockets.HasPlayerConnection(playerId) {
+				if err := game.MarkDisconnected(gameId, playerId); err != nil {
+					slog.ErrorContext(r.Context(), "Error marking player disconnected", "error", err)
+				}
+			}
+			return
+		case <-streamsCtx.Done():
+			// The player is reconnecting to another replica, so they aren't marked disconnected
+			return
+		case <-overflowed:
+			slog.WarnContext(r.Context(), "closing slow event stream")
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case message := <-events:
+			seq := game.ParseEventSeq(message)
+			if seq != 0 && seq <= backlogSeq {
+				continue
+			}
+			if !send(message, seq) {
+				return
+			}
+			if game.IsKickedEvent(message, playerId) {
+				flusher.Flush()
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"scribl-clone/data"
+	gameLogic "scribl-clone/game"
 
 	"github.com/go-chi/chi"
 )
@@ -22,7 +23,13 @@ func GetGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	payload, err := json.Marshal(game)
+	code, err := gameLogic.GetRoomCode(game.Id)
+	if err != nil {
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
+
+	payload, err := json.Marshal(gameWithRoomCode{PublicGame: game.WithoutWord(), RoomCode: code})
 	if err != nil {
 		http.Error(w, http.StatusText(500), 500)
 		return
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"scribl-clone/data"
 	"scribl-clone/game"
+	"scribl-clone/moderation"
 	"scribl-clone/player"
+	"scribl-clone/utils"
 
 	"github.com/go-chi/chi"
 )
@@ -16,62 +20,84 @@ type bodySchema struct {
 }
 
 type returnSchema struct {
-	Player *data.Player `json:"player"`
-	Token  string       `json:"token"`
+	Player data.PublicPlayer `json:"player"`
+	Token  string            `json:"token"`
 }
 
+// Adds a player to a game, which can be given by its id or its room code.
 func JoinGame(w http.ResponseWriter, r *http.Request) {
-	gameId := chi.URLParam(r, "gameId")
+	gameId, err := game.ResolveGameId(chi.URLParam(r, "gameId"))
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
 
 	body := bodySchema{}
-	err := json.NewDecoder(r.Body).Decode(&body)
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// ====== Fetch Required data ======
-	g, err := data.GetGame(gameId)
+
+	joined, err := addPlayerToGame(r, gameId, body.Name)
 	if err != nil {
-		slog.Error(err.Error())
-		http.Error(w, http.StatusText(500), 500)
+		handleGameError(w, r, err)
 		return
 	}
-	if g == nil {
-		http.Error(w, http.StatusText(404), 404)
+
+	payload, err := json.Marshal(joined)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		http.Error(w, http.StatusText(500), 500)
 		return
 	}
 
-	// TODO: add in limit to the number of players that can join
+	w.Write(payload)
+}
+
+// Creates a player in the game and announces them, returning the player and their token.
+func addPlayerToGame(r *http.Request, gameId string, name string) (*returnSchema, error) {
+	name, err := moderation.Apply(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// ====== Fetch Required data ======
+	g, err := data.GetGame(gameId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, utils.ErrResourceNotFound
+	}
 
 	// ======= Create Player ========
-	playerId, err := data.CreatePlayer(body.Name, gameId)
+	playerId, created, err := data.CreatePlayer(name, gameId)
 	if err != nil {
-		slog.Error(err.Error())
-		http.Error(w, http.StatusText(500), 500)
-		return
+		return nil, err
+	}
+	if !created {
+		return nil, game.ErrGameFull
 	}
 
 	p, err := data.GetPlayer(playerId)
 	if err != nil {
-		slog.Error(err.Error())
-		http.Error(w, http.StatusText(500), 500)
-		return
+		return nil, err
 	}
 
 	game.AddPlayer(gameId, p)
+	if err := game.RefreshRoomCode(gameId); err != nil {
+		slog.ErrorContext(r.Context(), "Error refreshing room code", "error", err)
+	}
 
-	payload, err := json.Marshal(returnSchema{
+	return &returnSchema{
 		Token: player.GenerateToken(player.PlayerClaim{
 			PlayerId: playerId,
 			GameId:   gameId,
 		}),
-		Player: p,
-	})
-	if err != nil {
-		slog.Error(err.Error())
-		http.Error(w, http.StatusText(500), 500)
-		return
-	}
-
-	w.Write(payload)
+		Player: p.Public(),
+	}, nil
 }
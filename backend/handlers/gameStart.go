@@ -25,7 +25,7 @@ func StartGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		slog.Error(err.Error())
+		slog.ErrorContext(r.Context(), err.Error())
 		http.Error(w, http.StatusText(500), 500)
 		return
 	}
@@ -47,17 +47,28 @@ func StartGame(w http.ResponseWriter, r *http.Request) {
 	`, gameId).Scan(&playerId)
 
 	if err != nil {
-		slog.Error(err.Error())
+		slog.ErrorContext(r.Context(), err.Error())
 		http.Error(w, http.StatusText(500), 500)
 		return
 	}
 
-	_, err = db.Exec(`UPDATE game SET state = $2, turn = $3 WHERE id = $1;`, g.Id, data.GAME_STATE_SELECTING_WORD, playerId)
+	// Another request may have started the game in the meantime
+	result, err := db.Exec(
+		`UPDATE game SET state = $2, turn = $3 WHERE id = $1 AND state = $4;`,
+		g.Id,
+		data.GAME_STATE_SELECTING_WORD,
+		playerId,
+		data.GAME_STATE_WAITING_FOR_PLAYERS,
+	)
 	if err != nil {
-		slog.Error(err.Error())
+		slog.ErrorContext(r.Context(), err.Error())
 		http.Error(w, "Bad Request", http.StatusInternalServerError)
 		return
 	}
+	if started, err := result.RowsAffected(); err != nil || started == 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 	game.StartRound(gameId, playerId)
 
 	w.Write(utils.STANDARD_SUCCESS_RESPONSE)
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"time"
+)
+
+// How long each dependency gets to answer a readiness check
+const READINESS_TIMEOUT = 2 * time.Second
+
+// Reports that the process is up. It doesn't touch any dependency so a database outage doesn't
+// get the server restarted.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"status": "ok"}`))
+}
+
+// Reports whether the database and the broker are reachable, so traffic is only routed to
+// instances that can serve it.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(context.Context) error{
+		"database": data.Ping,
+		"broker":   eventListener.Ping,
+	}
+
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), READINESS_TIMEOUT)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "ok"
+	}
+
+	payload, _ := json.Marshal(results)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The lobby is sent to a feed at most this often, changes in between are sent together
+const LOBBY_FEED_INTERVAL = time.Second
+
+// Streams the lobby as server-sent events. The list of games matching the same filters as GetLobby
+// is sent when the stream opens and again whenever it changes.
+func StreamLobby(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLobbyFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.HandleError(w, r, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	// Holds the time of the latest change that hasn't been sent. There is only one listener per
+	// channel, so replacing the value can't race with another change.
+	changes := make(chan time.Time, 1)
+	unwatch := game.WatchLobby("lobby/"+uuid.NewString(), func() {
+		changedAt := time.Now()
+		select {
+		case <-changes:
+		default:
+		}
+		changes <- changedAt
+	})
+	defer unwatch()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", EVENT_STREAM_RETRY)
+
+	var lastPayload []byte
+	var lastSent time.Time
+	send := func(since time.Time) bool {
+		lastSent = time.Now()
+		entries, err := game.ListLobbyAfter(filter, since)
+		if err != nil {
+			// The next change or reconnect tries again
+			slog.ErrorContext(r.Context(), "Error listing lobby", "error", err)
+			return true
+		}
+		payload, err := json.Marshal(entries)
+		if err != nil || bytes.Equal(payload, lastPayload) {
+			return true
+		}
+		lastPayload = payload
+		if _, err := fmt.Fprintf(w, "event: lobby\ndata: %s\n\n", payload); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send(time.Now().Add(-game.LOBBY_CACHE_TTL)) {
+		return
+	}
+
+	keepalive := time.NewTicker(EVENT_STREAM_KEEPALIVE)
+	defer keepalive.Stop()
+	var pending time.Time
+	var due <-chan time.Time
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-streamsCtx.Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case changedAt := <-changes:
+			pending = changedAt
+			if due == nil {
+				due = time.After(max(LOBBY_FEED_INTERVAL-time.Since(lastSent), 0))
+			}
+		case <-due:
+			due = nil
+			if !send(pending) {
+				return
+			}
+		}
+	}
+}
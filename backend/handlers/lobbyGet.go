@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/utils"
+	"strconv"
+	"strings"
+)
+
+// Lists public games. Games can be filtered with the query parameters language, wordPack, state
+// (a comma separated list of states), minPlayers, maxPlayers and open, which leaves out full games.
+func GetLobby(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLobbyFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := game.ListLobby(filter)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
+
+func parseLobbyFilter(r *http.Request) (game.LobbyFilter, error) {
+	query := r.URL.Query()
+	filter := game.LobbyFilter{
+		Language: query.Get("language"),
+		WordPack: query.Get("wordPack"),
+	}
+
+	if states := query.Get("state"); states != "" {
+		for _, state := range strings.Split(states, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(state))
+			if err != nil {
+				return filter, &game.GameError{Message: "Invalid state " + state}
+			}
+			filter.States = append(filter.States, n)
+		}
+	}
+
+	var err error
+	if minPlayers := query.Get("minPlayers"); minPlayers != "" {
+		if filter.MinPlayers, err = strconv.Atoi(minPlayers); err != nil {
+			return filter, &game.GameError{Message: "Invalid minPlayers"}
+		}
+	}
+	if maxPlayers := query.Get("maxPlayers"); maxPlayers != "" {
+		if filter.MaxPlayers, err = strconv.Atoi(maxPlayers); err != nil {
+			return filter, &game.GameError{Message: "Invalid maxPlayers"}
+		}
+	}
+	if open := query.Get("open"); open != "" {
+		if filter.Open, err = strconv.ParseBool(open); err != nil {
+			return filter, &game.GameError{Message: "Invalid open"}
+		}
+	}
+	return filter, nil
+}
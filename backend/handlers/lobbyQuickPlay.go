@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/moderation"
+	"scribl-clone/utils"
+)
+
+// Times a player is matched into an existing game before a new one is made for them, other
+// players can fill a game between it being picked and the player joining it
+const QUICK_PLAY_ATTEMPTS = 3
+
+type quickPlayBodySchema struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	WordPack string `json:"wordPack"`
+}
+
+type quickPlayReturnSchema struct {
+	returnSchema
+	GameId   string `json:"gameId"`
+	RoomCode string `json:"roomCode"`
+}
+
+// Puts a player into the best open public game in their language and word pack, creating a
+// public game for them when there is none.
+func QuickPlay(w http.ResponseWriter, r *http.Request) {
+	body := quickPlayBodySchema{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Checked up front so a rejected name doesn't leave an empty game behind
+	name, err := moderation.Apply(body.Name)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	settings := data.GameSettings{
+		Public:   true,
+		Language: body.Language,
+		WordPack: body.WordPack,
+	}.WithDefaults()
+
+	for range QUICK_PLAY_ATTEMPTS {
+		gameId, err := game.FindQuickPlayGame(settings.Language, settings.WordPack)
+		if err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+		if gameId == "" {
+			break
+		}
+		joined, err := addPlayerToGame(r, gameId, name)
+		if errors.Is(err, game.ErrGameFull) {
+			continue
+		}
+		if err != nil {
+			handleGameError(w, r, err)
+			return
+		}
+		writeQuickPlay(w, r, gameId, joined)
+		return
+	}
+
+	g, _, err := game.NewGame(settings)
+	if err != nil {
+		handleGameError(w, r, err)
+		return
+	}
+	joined, err := addPlayerToGame(r, g.Id, name)
+	if err != nil {
+		handleGameError(w, r, err)
+		return
+	}
+	writeQuickPlay(w, r, g.Id, joined)
+}
+
+func writeQuickPlay(w http.ResponseWriter, r *http.Request, gameId string, joined *returnSchema) {
+	code, err := game.GetRoomCode(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(quickPlayReturnSchema{
+		returnSchema: *joined,
+		GameId:       gameId,
+		RoomCode:     code,
+	})
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
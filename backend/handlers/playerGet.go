@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"scribl-clone/data"
+	"scribl-clone/utils"
 
 	"github.com/go-chi/chi"
 )
@@ -12,20 +12,15 @@ import (
 func GetPlayer(w http.ResponseWriter, r *http.Request) {
 	playerId := chi.URLParam(r, "playerId")
 
-	player, err := data.GetPlayer(playerId)
+	player, err := getVisiblePlayer(r, playerId)
 	if err != nil {
-		slog.Error(err.Error())
-		http.Error(w, http.StatusText(500), 500)
-		return
-	}
-	if player == nil {
-		http.Error(w, http.StatusText(404), 404)
+		utils.HandleError(w, r, err)
 		return
 	}
 
-	payload, err := json.Marshal(player)
+	payload, err := json.Marshal(player.Public())
 	if err != nil {
-		slog.Error(err.Error())
+		slog.ErrorContext(r.Context(), err.Error())
 		http.Error(w, http.StatusText(500), 500)
 		return
 	}
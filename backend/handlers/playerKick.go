@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+)
+
+// Lets the host of a game kick another player out of it.
+func KickPlayer(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+	playerId := chi.URLParam(r, "playerId")
+
+	claim, err := player.AuthorizeRequest(r)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if claim.GameId != gameId {
+		utils.HandleError(w, r, player.ErrUnauthorized)
+		return
+	}
+
+	hostId, err := data.GetHostId(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if claim.PlayerId != hostId {
+		http.Error(w, "Only the host can kick players", http.StatusForbidden)
+		return
+	}
+	if playerId == hostId {
+		http.Error(w, "The host can't kick themselves", http.StatusBadRequest)
+		return
+	}
+
+	if err := game.KickPlayer(gameId, playerId); err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(utils.STANDARD_SUCCESS_RESPONSE)
+}
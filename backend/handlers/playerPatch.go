@@ -5,36 +5,51 @@ import (
 	"net/http"
 	"scribl-clone/data"
 	"scribl-clone/game"
+	"scribl-clone/moderation"
 	"scribl-clone/player"
 	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
 )
 
 func PatchPlayer(w http.ResponseWriter, r *http.Request) {
 	claim, err := player.AuthorizeRequest(r)
 	if err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 
-	playerId := claim.PlayerId
+	playerId := chi.URLParam(r, "playerId")
+	if playerId != claim.PlayerId {
+		utils.HandleError(w, r, utils.ErrForbidden)
+		return
+	}
 
 	updateSet := make(map[string]any)
 	if err = json.NewDecoder(r.Body).Decode(&updateSet); err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 
+	if name, ok := updateSet["Name"].(string); ok {
+		if updateSet["Name"], err = moderation.Apply(name); err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+	}
+
 	updatedPlayer, err := data.UpdatePlayer(playerId, updateSet)
 	if err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 
-	game.UpdatePlayer(claim.GameId, playerId, updateSet)
+	// Name is the only field players can change, it is sent as stored rather than as requested
+	game.UpdatePlayer(claim.GameId, playerId, map[string]any{"Name": updatedPlayer.Name})
 
-	payload, err := json.Marshal(updatedPlayer)
+	payload, err := json.Marshal(updatedPlayer.Public())
 	if err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 
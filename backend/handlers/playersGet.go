@@ -1,12 +1,11 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
 	"log/slog"
 	"net/http"
 	"scribl-clone/data"
+	"scribl-clone/utils"
 
 	"github.com/go-chi/chi"
 )
@@ -14,20 +13,26 @@ import (
 func GetPlayers(w http.ResponseWriter, r *http.Request) {
 	gameId := chi.URLParam(r, "gameId")
 
-	db := data.GetDb()
+	if _, err := authorizeGameMember(r, gameId); err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	players, err := data.GetPlayers(gameId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), err.Error())
+		http.Error(w, http.StatusText(500), 500)
+		return
+	}
 
-	players := []data.Player{}
-	if err := db.Select(&players, `SELECT * FROM player WHERE game = $1`, gameId); err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			slog.Error(err.Error())
-			http.Error(w, http.StatusText(500), 500)
-			return
-		}
+	publicPlayers := make([]data.PublicPlayer, 0, len(players))
+	for i := range players {
+		publicPlayers = append(publicPlayers, players[i].Public())
 	}
 
-	payload, err := json.Marshal(players)
+	payload, err := json.Marshal(publicPlayers)
 	if err != nil {
-		slog.Error(err.Error())
+		slog.ErrorContext(r.Context(), err.Error())
 		http.Error(w, http.StatusText(500), 500)
 		return
 	}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/replay"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+)
+
+// Downloads the recording of a game, as JSON or as NDJSON when ?format=ndjson is given. Games that
+// aren't open to spectators can only be downloaded by their players. Either way the recording
+// holds what a spectator would have seen, so it never gives the word away.
+func GetReplay(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	g, err := data.GetGame(gameId)
+	if err != nil || g == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !game.OpenToSpectators(g) {
+		if _, err := authorizeGameMember(r, gameId); err != nil {
+			utils.HandleError(w, r, err)
+			return
+		}
+	}
+
+	rep, err := replay.Load(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	rep.Events = spectatorRecords(rep.Events)
+	if len(rep.Events) == 0 {
+		http.Error(w, http.StatusText(404), 404)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+gameId+`.replay.json"`)
+		err = rep.WriteJSON(w)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+gameId+`.replay.ndjson"`)
+		err = rep.WriteNDJSON(w)
+	default:
+		http.Error(w, "Unknown format", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.HandleError(w, r, err)
+	}
+}
+
+// Keeps the records spectators received, as they received them.
+func spectatorRecords(records []replay.Record) []replay.Record {
+	kept := make([]replay.Record, 0, len(records))
+	for _, record := range records {
+		event, ok := game.PrepareForDownload(string(record.Event))
+		if !ok {
+			continue
+		}
+		record.Event = json.RawMessage(event)
+		kept = append(kept, record)
+	}
+	return kept
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+)
+
+// Looks up the game a room code belongs to.
+func GetRoom(w http.ResponseWriter, r *http.Request) {
+	code := game.NormaliseRoomCode(chi.URLParam(r, "code"))
+
+	gameId, err := game.ResolveRoomCode(code)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(struct {
+		GameId   string `json:"gameId"`
+		RoomCode string `json:"roomCode"`
+	}{
+		GameId:   gameId,
+		RoomCode: code,
+	})
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import "context"
+
+// Done when the server is shutting down. Event streams only end when their client goes away, so
+// they also stop on this or the server would wait for them until its shutdown timeout.
+var streamsCtx, closeStreams = context.WithCancel(context.Background())
+
+// Ends every open event stream, clients reconnect to another replica after EVENT_STREAM_RETRY.
+func CloseStreams() {
+	closeStreams()
+}
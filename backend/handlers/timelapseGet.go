@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"scribl-clone/game"
+	"scribl-clone/renderer"
+	"scribl-clone/replay"
+	"scribl-clone/utils"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// Renders an animated GIF of how a turn's drawing was made. The frame rate is set with ?fps= and
+// the canvas size with ?width= and ?height=, up to renderer.MAX_TIMELAPSE_SIZE. The GIF of a
+// finished turn is rendered once and cached.
+func GetTurnTimelapse(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	turn, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil {
+		http.Error(w, "Invalid turn", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseDrawingOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Width > renderer.MAX_TIMELAPSE_SIZE || opts.Height > renderer.MAX_TIMELAPSE_SIZE {
+		http.Error(w, "invalid size", http.StatusBadRequest)
+		return
+	}
+
+	fps := renderer.DEFAULT_TIMELAPSE_FPS
+	if rawFps := r.URL.Query().Get("fps"); rawFps != "" {
+		fps, err = strconv.Atoi(rawFps)
+		if err != nil || fps < 1 || fps > renderer.MAX_TIMELAPSE_FPS {
+			http.Error(w, "invalid fps", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cached, ok, err := renderer.CachedTimelapse(gameId, turn, fps, opts)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error reading cached timelapse", "error", err)
+	}
+	if ok {
+		w.Header().Set("Content-Type", "image/gif")
+		w.Write(cached)
+		return
+	}
+
+	rep, err := replay.Load(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	frames, finished, err := renderer.Timelapse(rep, turn, fps)
+	if err != nil {
+		if errors.Is(err, game.ErrTurnNotFound) {
+			http.Error(w, http.StatusText(404), 404)
+			return
+		}
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	encoded := bytes.Buffer{}
+	if err := renderer.RenderGIF(&encoded, frames, opts); err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if finished {
+		if err := renderer.CacheTimelapse(gameId, turn, fps, opts, encoded.Bytes()); err != nil {
+			slog.ErrorContext(r.Context(), "Error caching timelapse", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/gif")
+	w.Write(encoded.Bytes())
+}
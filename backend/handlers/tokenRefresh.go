@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+	"time"
+)
+
+// Exchanges a token that is valid or recently expired for a new one, as long as the player is
+// still in the game.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	claim, token, err := player.RefreshToken(player.GetBearerToken(r))
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	p, err := data.GetPlayer(claim.PlayerId)
+	if err != nil || p.Game != claim.GameId || p.ActiveState == data.PLAYER_STATE_KICKED {
+		utils.HandleError(w, r, player.ErrUnauthorized)
+		return
+	}
+
+	payload, err := json.Marshal(struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}{
+		Token:     token,
+		ExpiresAt: time.Now().Add(player.TOKEN_LIFETIME),
+	})
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	w.Write(payload)
+}
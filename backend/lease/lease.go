@@ -0,0 +1,82 @@
+package lease
+
+// This module hands out time limited, exclusive ownership of a key to one holder at a time. A
+// holder has to renew its lease before it expires, so a holder that dies loses it on its own.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Takes the lease if it is free, or extends it if the holder already has it.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Leases stored in redis, shared by every replica using the same redis.
+type Leases struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func New(rdb *redis.Client, ttl time.Duration) *Leases {
+	return &Leases{rdb: rdb, ttl: ttl}
+}
+
+func (l *Leases) TTL() time.Duration {
+	return l.ttl
+}
+
+// Reports whether holder has the lease on key after the call.
+func (l *Leases) Acquire(ctx context.Context, key string, holder string) (bool, error) {
+	return l.run(ctx, acquireScript, key, holder, l.ttl.Milliseconds())
+}
+
+// Extends a lease, reporting false if holder lost it in the meantime.
+func (l *Leases) Renew(ctx context.Context, key string, holder string) (bool, error) {
+	return l.run(ctx, renewScript, key, holder, l.ttl.Milliseconds())
+}
+
+// Gives up a lease early so another holder can take it straight away.
+func (l *Leases) Release(ctx context.Context, key string, holder string) error {
+	_, err := l.run(ctx, releaseScript, key, holder)
+	return err
+}
+
+// Returns who holds the lease on key, or "" if nobody does.
+func (l *Leases) Holder(ctx context.Context, key string) (string, error) {
+	holder, err := l.rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return holder, err
+}
+
+func (l *Leases) run(ctx context.Context, script *redis.Script, key string, args ...any) (bool, error) {
+	result, err := script.Run(ctx, l.rdb, []string{key}, args...).Int()
+	return result == 1, err
+}
@@ -0,0 +1,37 @@
+package logging
+
+// This module carries the ids a log record is about, so records logged while handling a request or
+// a websocket message can be tied back to it.
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type attrsKey struct{}
+
+// Returns a context whose log records carry args, given as key value pairs like slog.With.
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append(slices.Clone(attrsFromContext(ctx)), argsToAttrs(args)...)
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+func attrsFromContext(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+func argsToAttrs(args []any) []slog.Attr {
+	record := slog.Record{}
+	record.Add(args...)
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Route parameters that are copied onto records logged while serving a request, and the keys they
+// are logged under. The player in the route isn't necessarily the one making the request.
+var ROUTE_PARAMS = map[string]string{
+	"gameId":   "gameId",
+	"playerId": "targetPlayerId",
+}
+
+// Wraps a handler to add the attributes carried by the record's context, the current span and the
+// route parameters, and to redact secrets.
+type Handler struct {
+	inner slog.Handler
+}
+
+func NewHandler(inner slog.Handler) *Handler {
+	return &Handler{inner: inner}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, RedactString(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	redacted.AddAttrs(contextAttrs(ctx, record)...)
+	return h.inner.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{inner: h.inner.WithAttrs(redactAttrs(attrs))}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name)}
+}
+
+// Returns the attributes the context adds to a record, skipping those the record already has.
+func contextAttrs(ctx context.Context, record slog.Record) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+
+	has := map[string]bool{}
+	record.Attrs(func(attr slog.Attr) bool {
+		has[attr.Key] = true
+		return true
+	})
+	attrs := []slog.Attr{}
+	add := func(attr slog.Attr) {
+		if !has[attr.Key] {
+			has[attr.Key] = true
+			attrs = append(attrs, redactAttr(attr))
+		}
+	}
+
+	for _, attr := range attrsFromContext(ctx) {
+		add(attr)
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		for param, key := range ROUTE_PARAMS {
+			if value := rctx.URLParam(param); value != "" {
+				add(slog.String(key, value))
+			}
+		}
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		add(slog.String("traceId", spanContext.TraceID().String()))
+		add(slog.String("spanId", spanContext.SpanID().String()))
+	}
+	return attrs
+}
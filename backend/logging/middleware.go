@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// Attaches the request id, and whatever identify finds out about the requester, to records logged
+// with the request's context. It must run after middleware.RequestID.
+func Middleware(identify func(r *http.Request) []any) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			args := []any{"requestId", middleware.GetReqID(r.Context())}
+			if identify != nil {
+				args = append(args, identify(r)...)
+			}
+			next.ServeHTTP(w, r.WithContext(With(r.Context(), args...)))
+		})
+	}
+}
+
+// Logs a line for each request like middleware.Logger, but through slog so the URL is redacted
+// like every other record. Clients may send their token in the query string.
+var AccessLog = middleware.RequestLogger(&middleware.DefaultLogFormatter{Logger: accessLogger{}, NoColor: true})
+
+type accessLogger struct{}
+
+func (accessLogger) Print(v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprint(v...)))
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+// Attributes whose key contains one of these never have their value logged
+var SECRET_KEYS = []string{"token", "password", "secret", "authorization", "cookie"}
+
+// JSON web tokens are three base64url segments, the first two starting with an encoded '{"'
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`)
+
+// Query parameters clients put tokens in, as in a logged request URL
+var tokenParamPattern = regexp.MustCompile(`([?&]token=)[^&\s"]*`)
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range SECRET_KEYS {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Replaces tokens that made their way into a message.
+func RedactString(s string) string {
+	if strings.Contains(s, "token=") {
+		s = tokenParamPattern.ReplaceAllString(s, "${1}"+REDACTED)
+	}
+	if !strings.Contains(s, "eyJ") {
+		return s
+	}
+	return jwtPattern.ReplaceAllString(s, REDACTED)
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if isSecretKey(attr.Key) {
+		return slog.String(attr.Key, REDACTED)
+	}
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]any, len(group))
+		for i, a := range group {
+			redacted[i] = redactAttr(a)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, RedactString(err.Error()))
+		}
+	}
+	return attr
+}
+
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return redacted
+}
@@ -1,11 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"scribl-clone/config"
+	"scribl-clone/data"
+	"scribl-clone/eventListener"
+	"scribl-clone/game"
 	"scribl-clone/handlers"
+	"scribl-clone/logging"
+	"scribl-clone/metrics"
+	"scribl-clone/moderation"
+	"scribl-clone/player"
+	"scribl-clone/ratelimit"
 	"scribl-clone/sockets"
+	"scribl-clone/tracing"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -13,48 +28,223 @@ import (
 	"github.com/go-chi/cors"
 )
 
-func configureLogger() {
+func configureLogger(cfg config.LogConfig) {
 	var programLevel = new(slog.LevelVar)
 	h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: programLevel})
-	slog.SetDefault(slog.New(h))
-	programLevel.Set(slog.LevelDebug)
+	slog.SetDefault(slog.New(logging.NewHandler(h)))
+	level, _ := cfg.SlogLevel()
+	programLevel.Set(level)
+}
+
+// Labels the request's logs with the player sending it, if they sent a token.
+func identifyRequester(r *http.Request) []any {
+	claim, ok := player.IdentifyRequest(r)
+	if !ok {
+		return nil
+	}
+	return []any{"playerId", claim.PlayerId, "gameId", claim.GameId}
+}
+
+// Skips a middleware for server-sent event streams, which stay open for as long as the client
+// listens.
+func unlessEventStream(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Rate limits are kept in memory unless the redis store is configured, which shares them between
+// replicas.
+func configureRateLimitStore(cfg config.RateLimitConfig) ratelimit.Store {
+	if cfg.Store == "redis" {
+		store := ratelimit.NewRedisStore(eventListener.GetPubSub())
+		game.SetRateLimitStore(store)
+		return store
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+func configureSigningKeys(cfg config.AuthConfig) error {
+	if len(cfg.SigningKeys) == 0 {
+		if !cfg.AllowDevelopmentKey {
+			return errors.New("no signing keys configured, set JWT_SIGNING_KEYS or allow the development key with JWT_ALLOW_DEVELOPMENT_KEY=true")
+		}
+		slog.Warn("no signing keys configured, using the development key")
+		player.UseDevelopmentKey()
+		return nil
+	}
+	keys := make(map[string][]byte, len(cfg.SigningKeys))
+	for id, secret := range cfg.SigningKeys {
+		keys[id] = []byte(secret)
+	}
+	return player.ConfigureKeys(cfg.ActiveKey, keys)
+}
+
+func configureModeration(cfg config.ModerationConfig) error {
+	return moderation.Configure(moderation.Options{
+		Mode:          moderation.Mode(cfg.Mode),
+		Languages:     cfg.Languages,
+		WordListFiles: cfg.WordListFiles,
+		AllowList:     cfg.AllowList,
+	})
 }
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	configureLogger(cfg.Log)
+	shutdownTracing, err := tracing.Configure(cfg.Tracing)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if err := data.Connect(cfg.Database); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	eventListener.Connect(cfg.Redis)
+	if err := configureSigningKeys(cfg.Auth); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if err := configureModeration(cfg.Moderation); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	sockets.Configure(cfg.Sockets)
+
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-
-	configureLogger()
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(identifyRequester))
+	r.Use(logging.AccessLog)
+	r.Use(metrics.Middleware)
+	r.Use(unlessEventStream(middleware.Timeout(cfg.Server.RequestTimeout)))
 
 	r.Use(cors.Handler(cors.Options{
-		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
-		AllowedOrigins: []string{"https://*", "http://*", "ws://*"},
+		AllowedOrigins: cfg.Server.AllowedOrigins,
 		// AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
-	r.Post("/game/new", handlers.CreateGame)
+	r.Get("/healthz", handlers.Healthz)
+	r.Get("/readyz", handlers.Readyz)
+	r.Get("/metrics", metrics.Handler().ServeHTTP)
+	r.Get("/debug/status", handlers.DebugStatus(cfg.Debug.Token))
+
+	limiter := ratelimit.New(configureRateLimitStore(cfg.RateLimit))
+
+	r.With(limiter.LimitPerIP("create", ratelimit.Limit{Requests: 5, Window: time.Minute})).
+		Post("/game/new", handlers.CreateGame)
 	r.Post("/game/{gameId}/start", handlers.StartGame)
-	r.Post("/game/{gameId}/join", handlers.JoinGame)
+	r.With(limiter.LimitPerIP("join", ratelimit.Limit{Requests: 10, Window: time.Minute})).
+		Post("/game/{gameId}/join", handlers.JoinGame)
 	r.Post("/game/{gameId}/select_word", handlers.SelectWord)
 	r.Post("/game/{gameId}/guess", handlers.MakeGuess)
+	r.With(limiter.Limit("chat", ratelimit.Limit{Requests: 10, Window: 5 * time.Second})).
+		Post("/game/{gameId}/chat", handlers.SendChat)
+	r.Get("/game/{gameId}/chat", handlers.GetChat)
 	r.Get("/game/{gameId}/dummy_event", handlers.DummyEvent)
 	r.Get("/game/{gameId}/players", handlers.GetPlayers)
+	r.Post("/game/{gameId}/players/{playerId}/kick", handlers.KickPlayer)
+	r.With(limiter.Limit("drawing", ratelimit.Limit{Requests: 30, Window: time.Minute})).
+		Get("/game/{gameId}/turns/{n}/drawing.{format}", handlers.GetTurnDrawing)
+	r.With(limiter.Limit("timelapse", ratelimit.Limit{Requests: 10, Window: time.Minute})).
+		Get("/game/{gameId}/turns/{n}/timelapse.gif", handlers.GetTurnTimelapse)
+	r.With(limiter.Limit("gallery", ratelimit.Limit{Requests: 30, Window: time.Minute})).
+		Get("/game/{gameId}/gallery", handlers.GetGallery)
+	r.Get("/game/{gameId}/replay", handlers.GetReplay)
+	r.Get("/game/{gameId}/events", handlers.StreamGameEvents)
 	r.Get("/game/{gameId}", handlers.GetGame)
 
+	r.Get("/room/{code}", handlers.GetRoom)
+
+	r.Get("/lobby", handlers.GetLobby)
+	r.Get("/lobby/events", handlers.StreamLobby)
+	r.With(limiter.LimitPerIP("quick_play", ratelimit.Limit{Requests: 10, Window: time.Minute})).
+		Post("/lobby/quick_play", handlers.QuickPlay)
+
+	r.Post("/token/refresh", handlers.RefreshToken)
+
 	r.Get("/player/{playerId}", handlers.GetPlayer)
 	r.Patch("/player/{playerId}", handlers.PatchPlayer)
 
 	r.Get("/game_connection/{userId}", sockets.InitGameConnection)
+	r.Get("/spectate_connection/{gameId}", sockets.SpectateGame)
+	r.Get("/replay_connection/{gameId}", sockets.PlayReplay)
+
+	if err := game.DefaultNode().Start(); err != nil {
+		slog.Error("Error starting node", "error", err)
+	}
+	sweepCtx, stopSweeping := context.WithCancel(context.Background())
+	defer stopSweeping()
+	go game.SweepExpiredGames(sweepCtx)
+
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: r}
+	server.RegisterOnShutdown(handlers.CloseStreams)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
 
-	if err := http.ListenAndServe(":4000", r); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
 		slog.Error(err.Error())
 		return
+	case <-ctx.Done():
+	}
+
+	shutdown(server, cfg.Server.ShutdownTimeout, shutdownTracing)
+}
+
+// Stops accepting connections and waits for requests in flight, ends the event streams, closes
+// every websocket with a service restart close frame, stops the turn timers, whose deadlines are kept so the next
+// process resumes them, then closes the subscriptions and the database.
+func shutdown(server *http.Server, timeout time.Duration, shutdownTracing func(context.Context) error) {
+	slog.Info("shutting down", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- server.Shutdown(ctx)
+	}()
+
+	deadline, _ := ctx.Deadline()
+	sockets.CloseAll(deadline)
+	game.DefaultNode().Stop()
+
+	if err := <-drained; err != nil {
+		slog.Error("Error draining connections", "error", err)
+	}
+	if err := eventListener.Close(); err != nil {
+		slog.Error("Error closing redis", "error", err)
+	}
+	if err := data.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing spans", "error", err)
 	}
+	slog.Info("shut down")
 }
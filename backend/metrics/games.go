@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"log/slog"
+	"scribl-clone/data"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var activeGamesDesc = prometheus.NewDesc(
+	"scribl_active_games",
+	"Games that haven't ended and were created in the last day, by state.",
+	[]string{"state"}, nil,
+)
+
+// Counts games when scraped, since the database is shared by every instance.
+type gamesCollector struct{}
+
+func (gamesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeGamesDesc
+}
+
+func (gamesCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := data.CountActiveGames()
+	if err != nil {
+		slog.Error("Error counting active games", "error", err)
+		return
+	}
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(activeGamesDesc, prometheus.GaugeValue, float64(count), strconv.Itoa(state))
+	}
+}
+
+func init() {
+	prometheus.MustRegister(gamesCollector{})
+}
@@ -0,0 +1,51 @@
+package metrics
+
+// This module defines the prometheus metrics exported on /metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var WebsocketsConnected = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "scribl_websockets_connected",
+	Help: "Websocket connections open on this instance.",
+})
+
+var EventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "scribl_events_published_total",
+	Help: "Game events published to redis, by event type.",
+}, []string{"type"})
+
+var EventsDelivered = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "scribl_events_delivered_total",
+	Help: "Game events written to websocket connections, by event type.",
+}, []string{"type"})
+
+var SlowConsumerActions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "scribl_slow_consumer_actions_total",
+	Help: "Messages superseded or coalesced, and connections closed, because a websocket's queue was full.",
+}, []string{"action"})
+
+var PublishDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "scribl_event_publish_duration_seconds",
+	Help:    "Time taken to publish a game event to redis.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+})
+
+var HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scribl_http_request_duration_seconds",
+	Help:    "Time taken to serve HTTP requests, by route pattern, method and status code.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+var Guesses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "scribl_guesses_total",
+	Help: "Guesses made while a word was being drawn, by result.",
+}, []string{"result"})
+
+var TurnDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "scribl_turn_duration_seconds",
+	Help:    "Time from a word being chosen until the turn ended.",
+	Buckets: prometheus.LinearBuckets(5, 5, 12),
+})
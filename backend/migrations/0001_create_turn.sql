@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS turn (
+    game         UUID        NOT NULL REFERENCES game (id) ON DELETE CASCADE,
+    number       INTEGER     NOT NULL,
+    word         TEXT        NOT NULL,
+    drawer       UUID        NOT NULL,
+    guessers     UUID[]      NOT NULL DEFAULT '{}',
+    lines        JSONB       NOT NULL DEFAULT '[]',
+    date_created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (game, number)
+);
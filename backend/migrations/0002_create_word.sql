@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS word (
+    id       SERIAL PRIMARY KEY,
+    word     TEXT   NOT NULL,
+    language TEXT   NOT NULL DEFAULT 'en',
+    pack     TEXT   NOT NULL DEFAULT 'default',
+    aliases  TEXT[] NOT NULL DEFAULT '{}',
+    UNIQUE (word, language, pack)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS chat_message (
+    id           SERIAL PRIMARY KEY,
+    game         UUID        NOT NULL REFERENCES game (id) ON DELETE CASCADE,
+    player       UUID        NOT NULL,
+    message      TEXT        NOT NULL,
+    kind         TEXT        NOT NULL,
+    correct      BOOLEAN     NOT NULL DEFAULT false,
+    turn         INTEGER     NOT NULL DEFAULT 0,
+    date_created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS chat_message_game_idx ON chat_message (game, id);
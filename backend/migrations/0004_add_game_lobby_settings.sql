@@ -0,0 +1,6 @@
+ALTER TABLE game
+    ADD COLUMN IF NOT EXISTS public    BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS language  TEXT    NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS word_pack TEXT    NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS game_lobby_idx ON game (public, state);
@@ -0,0 +1,121 @@
+-- Words for the default pack, so a fresh database can run games
+INSERT INTO word (word, aliases)
+VALUES
+    ('apple', '{}'),
+    ('banana', '{}'),
+    ('bicycle', '{"bike"}'),
+    ('bird', '{}'),
+    ('book', '{}'),
+    ('bridge', '{}'),
+    ('butterfly', '{}'),
+    ('cake', '{}'),
+    ('camera', '{}'),
+    ('candle', '{}'),
+    ('car', '{"automobile"}'),
+    ('castle', '{}'),
+    ('cat', '{}'),
+    ('chair', '{}'),
+    ('cheese', '{}'),
+    ('clock', '{}'),
+    ('cloud', '{}'),
+    ('computer', '{}'),
+    ('cookie', '{}'),
+    ('cow', '{}'),
+    ('crown', '{}'),
+    ('dog', '{}'),
+    ('dolphin', '{}'),
+    ('door', '{}'),
+    ('dragon', '{}'),
+    ('drum', '{}'),
+    ('duck', '{}'),
+    ('elephant', '{}'),
+    ('eye', '{}'),
+    ('feather', '{}'),
+    ('fire', '{}'),
+    ('fish', '{}'),
+    ('flower', '{}'),
+    ('fork', '{}'),
+    ('frog', '{}'),
+    ('ghost', '{}'),
+    ('giraffe', '{}'),
+    ('glasses', '{"spectacles"}'),
+    ('guitar', '{}'),
+    ('hammer', '{}'),
+    ('hat', '{}'),
+    ('heart', '{}'),
+    ('helicopter', '{"chopper"}'),
+    ('horse', '{}'),
+    ('house', '{}'),
+    ('ice cream', '{"icecream"}'),
+    ('island', '{}'),
+    ('jellyfish', '{}'),
+    ('kangaroo', '{"roo"}'),
+    ('key', '{}'),
+    ('kite', '{}'),
+    ('ladder', '{}'),
+    ('lamp', '{}'),
+    ('leaf', '{}'),
+    ('lemon', '{}'),
+    ('lighthouse', '{}'),
+    ('lion', '{}'),
+    ('lizard', '{}'),
+    ('map', '{}'),
+    ('monkey', '{}'),
+    ('moon', '{}'),
+    ('mountain', '{}'),
+    ('mouse', '{"mice"}'),
+    ('mushroom', '{}'),
+    ('octopus', '{}'),
+    ('owl', '{}'),
+    ('paint', '{}'),
+    ('pancake', '{}'),
+    ('panda', '{}'),
+    ('pencil', '{}'),
+    ('penguin', '{}'),
+    ('piano', '{}'),
+    ('pig', '{}'),
+    ('pineapple', '{}'),
+    ('pirate', '{}'),
+    ('pizza', '{}'),
+    ('planet', '{}'),
+    ('rabbit', '{"bunny"}'),
+    ('rainbow', '{}'),
+    ('robot', '{}'),
+    ('rocket', '{}'),
+    ('sandwich', '{"sarnie"}'),
+    ('scissors', '{}'),
+    ('shark', '{}'),
+    ('sheep', '{}'),
+    ('ship', '{}'),
+    ('shoe', '{}'),
+    ('skateboard', '{}'),
+    ('snail', '{}'),
+    ('snake', '{}'),
+    ('snowman', '{}'),
+    ('spider', '{}'),
+    ('spoon', '{}'),
+    ('star', '{}'),
+    ('sun', '{}'),
+    ('sunflower', '{}'),
+    ('sword', '{}'),
+    ('table', '{}'),
+    ('teapot', '{}'),
+    ('telephone', '{"phone"}'),
+    ('tent', '{}'),
+    ('tiger', '{}'),
+    ('toothbrush', '{}'),
+    ('tractor', '{}'),
+    ('train', '{}'),
+    ('tree', '{}'),
+    ('truck', '{}'),
+    ('turtle', '{}'),
+    ('umbrella', '{}'),
+    ('unicorn', '{}'),
+    ('volcano', '{}'),
+    ('watch', '{}'),
+    ('waterfall', '{}'),
+    ('whale', '{}'),
+    ('window', '{}'),
+    ('witch', '{}'),
+    ('zebra', '{}')
+ON CONFLICT (word, language, pack) DO NOTHING;
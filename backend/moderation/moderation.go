@@ -0,0 +1,241 @@
+package moderation
+
+// This module filters offensive words out of text players send to each other
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Mode string
+
+const (
+	// Text containing a blocked word is refused
+	MODE_REJECT Mode = "reject"
+	// Blocked words are replaced with asterisks
+	MODE_MASK Mode = "mask"
+	// Text is let through unchanged, but logged for review
+	MODE_FLAG Mode = "flag"
+)
+
+var ErrRejected = errors.New("content not allowed")
+
+//go:embed lists/*.txt
+var lists embed.FS
+
+type Options struct {
+	Mode Mode
+	// Languages whose built in word lists are used, all of them when empty
+	Languages []string
+	// Extra files with one blocked word per line
+	WordListFiles []string
+	// Words that are never blocked, even if they are in a word list
+	AllowList []string
+}
+
+type Filter struct {
+	mode    Mode
+	blocked map[string]bool
+	allowed map[string]bool
+}
+
+type Result struct {
+	Flagged bool
+	Text    string
+	Matches []string
+}
+
+func New(opts Options) (*Filter, error) {
+	if opts.Mode == "" {
+		opts.Mode = MODE_MASK
+	}
+	if opts.Mode != MODE_REJECT && opts.Mode != MODE_MASK && opts.Mode != MODE_FLAG {
+		return nil, fmt.Errorf("unknown moderation mode %q", opts.Mode)
+	}
+
+	f := &Filter{
+		mode:    opts.Mode,
+		blocked: make(map[string]bool),
+		allowed: make(map[string]bool),
+	}
+
+	languages := opts.Languages
+	if len(languages) == 0 {
+		entries, err := lists.ReadDir("lists")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			languages = append(languages, strings.TrimSuffix(entry.Name(), ".txt"))
+		}
+	}
+	for _, language := range languages {
+		file, err := lists.Open("lists/" + language + ".txt")
+		if err != nil {
+			return nil, fmt.Errorf("no word list for language %q", language)
+		}
+		err = f.addWords(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range opts.WordListFiles {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = f.addWords(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, word := range opts.AllowList {
+		f.allowed[normaliseToken(word)] = true
+		f.allowed[normalisePlain(word)] = true
+	}
+	return f, nil
+}
+
+func (f *Filter) addWords(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f.blocked[normaliseToken(line)] = true
+	}
+	return scanner.Err()
+}
+
+// Finds the blocked words in the text and returns the text with them masked.
+func (f *Filter) Check(text string) Result {
+	tokens := tokenise(text)
+	masked := make([]bool, len(tokens))
+	result := Result{}
+
+	for i, t := range tokens {
+		if f.isBlocked(t.forms...) {
+			masked[i] = true
+			result.Matches = append(result.Matches, t.text)
+		}
+	}
+
+	// Catch words spelled out with spaces between the letters, like "f u c k"
+	for start := 0; start < len(tokens); {
+		end := start
+		for end < len(tokens) && len([]rune(tokens[end].forms[0])) == 1 {
+			end++
+		}
+		if end-start > 1 {
+			joined := ""
+			for _, t := range tokens[start:end] {
+				joined += t.forms[0]
+			}
+			if f.isBlocked(joined) {
+				for i := start; i < end; i++ {
+					masked[i] = true
+				}
+				result.Matches = append(result.Matches, text[tokens[start].start:tokens[end-1].end])
+			}
+		}
+		start = max(end, start+1)
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, t := range tokens {
+		if !masked[i] {
+			continue
+		}
+		b.WriteString(text[last:t.start])
+		b.WriteString(strings.Repeat("*", len([]rune(t.text))))
+		last = t.end
+	}
+	b.WriteString(text[last:])
+
+	result.Flagged = len(result.Matches) > 0
+	result.Text = b.String()
+	return result
+}
+
+func (f *Filter) isBlocked(forms ...string) bool {
+	for _, form := range forms {
+		if form == "" || f.allowed[form] {
+			return false
+		}
+	}
+	for _, form := range forms {
+		if f.blocked[form] || f.blocked[collapseRepeats(form)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies the filter's mode to the text, returning the text that should be used in its place.
+func (f *Filter) Apply(text string) (string, error) {
+	result := f.Check(text)
+	if !result.Flagged {
+		return text, nil
+	}
+
+	switch f.mode {
+	case MODE_REJECT:
+		return "", ErrRejected
+	case MODE_FLAG:
+		slog.Warn("flagged content", "text", text, "matches", result.Matches)
+		return text, nil
+	}
+	return result.Text, nil
+}
+
+var (
+	defaultLock   sync.RWMutex
+	defaultFilter *Filter
+)
+
+// Replaces the filter used by Apply.
+func Configure(opts Options) error {
+	f, err := New(opts)
+	if err != nil {
+		return err
+	}
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultFilter = f
+	return nil
+}
+
+// Applies the configured filter, which masks words from every built in list unless Configure
+// has been called.
+func Apply(text string) (string, error) {
+	defaultLock.RLock()
+	f := defaultFilter
+	defaultLock.RUnlock()
+
+	if f == nil {
+		defaultLock.Lock()
+		if defaultFilter == nil {
+			var err error
+			if defaultFilter, err = New(Options{}); err != nil {
+				defaultLock.Unlock()
+				return "", err
+			}
+		}
+		f = defaultFilter
+		defaultLock.Unlock()
+	}
+	return f.Apply(text)
+}
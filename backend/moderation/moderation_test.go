@@ -0,0 +1,116 @@
+package moderation
+
+import (
+	"errors"
+	"testing"
+)
+
+func newEnglishFilter(t *testing.T, opts Options) *Filter {
+	t.Helper()
+	opts.Languages = []string{"en"}
+	f, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestCheck(t *testing.T) {
+	f := newEnglishFilter(t, Options{})
+
+	tests := []struct {
+		name    string
+		text    string
+		flagged bool
+		masked  string
+	}{
+		{"clean text", "a lovely drawing of a cat", false, "a lovely drawing of a cat"},
+		{"whole word", "what the fuck", true, "what the ****"},
+		{"upper case", "WHAT THE FUCK", true, "WHAT THE ****"},
+		{"punctuation around the word", "oh, shit!", true, "oh, *****"},
+		{"punctuation inside the word", "Sh.i.t happens", true, "****** happens"},
+		{"leetspeak", "you $h!t", true, "you ****"},
+		{"leetspeak digits", "b1tch please", true, "***** please"},
+		{"diacritics", "fück", true, "****"},
+		{"repeated letters", "fuuuuuck", true, "********"},
+		{"spaced letters", "f u c k off", true, "* * * * off"},
+		{"spaced letters in a sentence", "i said s h i t today", true, "i said * * * * today"},
+		{"exclamation isn't leetspeak at the end", "hello!", false, "hello!"},
+
+		// Words are only matched whole, so these known false positives get through
+		{"scunthorpe", "Scunthorpe United", false, "Scunthorpe United"},
+		{"cocktail", "a cocktail glass", false, "a cocktail glass"},
+		{"dickens", "Charles Dickens", false, "Charles Dickens"},
+		{"grass", "grass snake", false, "grass snake"},
+		{"assassin", "the assassin", false, "the assassin"},
+		{"therapist", "my therapist", false, "my therapist"},
+		{"spaced letters that spell nothing", "a b c d", false, "a b c d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := f.Check(tt.text)
+			if result.Flagged != tt.flagged {
+				t.Errorf("Check(%q).Flagged = %v, want %v", tt.text, result.Flagged, tt.flagged)
+			}
+			if result.Text != tt.masked {
+				t.Errorf("Check(%q).Text = %q, want %q", tt.text, result.Text, tt.masked)
+			}
+		})
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	f := newEnglishFilter(t, Options{AllowList: []string{"Dick", "prick"}})
+
+	tests := []struct {
+		text    string
+		flagged bool
+	}{
+		{"Moby Dick", false},
+		{"moby d1ck", false},
+		{"a prick of the needle", false},
+		{"what the fuck", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if flagged := f.Check(tt.text).Flagged; flagged != tt.flagged {
+				t.Errorf("Check(%q).Flagged = %v, want %v", tt.text, flagged, tt.flagged)
+			}
+		})
+	}
+}
+
+func TestApplyModes(t *testing.T) {
+	tests := []struct {
+		mode Mode
+		text string
+		want string
+		err  error
+	}{
+		{MODE_MASK, "oh shit", "oh ****", nil},
+		{MODE_REJECT, "oh shit", "", ErrRejected},
+		{MODE_REJECT, "oh dear", "oh dear", nil},
+		{MODE_FLAG, "oh shit", "oh shit", nil},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+tt.text, func(t *testing.T) {
+			f := newEnglishFilter(t, Options{Mode: tt.mode})
+			got, err := f.Apply(tt.text)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Apply(%q) error = %v, want %v", tt.text, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRejectsUnknownOptions(t *testing.T) {
+	if _, err := New(Options{Mode: "shout"}); err == nil {
+		t.Error("New accepted an unknown mode")
+	}
+	if _, err := New(Options{Languages: []string{"xx"}}); err == nil {
+		t.Error("New accepted a language without a word list")
+	}
+}
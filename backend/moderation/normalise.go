@@ -0,0 +1,90 @@
+package moderation
+
+import (
+	"scribl-clone/guess"
+	"strings"
+	"unicode"
+)
+
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'+': 't',
+	'|': 'l',
+}
+
+type token struct {
+	text string
+	// The token with and without leetspeak undone, as "hello!" isn't "helloi"
+	forms []string
+	start int
+	end   int
+}
+
+// Splits the text on whitespace, keeping where each token is so it can be masked.
+func tokenise(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, newToken(text, start, i))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text, start, len(text)))
+	}
+	return tokens
+}
+
+func newToken(text string, start int, end int) token {
+	return token{
+		text:  text[start:end],
+		forms: []string{normaliseToken(text[start:end]), normalisePlain(text[start:end])},
+		start: start,
+		end:   end,
+	}
+}
+
+// Undoes leetspeak, then lower cases and strips diacritics and punctuation, so "$h!t" and
+// "Sh.i.t" both become "shit".
+func normaliseToken(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if replacement, ok := leetspeak[r]; ok {
+			return replacement
+		}
+		return r
+	}, text)
+	return normalisePlain(text)
+}
+
+func normalisePlain(text string) string {
+	return strings.ReplaceAll(guess.Normalise(text), " ", "")
+}
+
+// Turns runs of the same letter into one, so stretched out words like "shiiit" are caught.
+func collapseRepeats(text string) string {
+	var b strings.Builder
+	var last rune = -1
+	for _, r := range text {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package player
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Used only when explicitly allowed, so the server can run locally without keys. It is public, so
+// never allow it in production.
+const DEVELOPMENT_KEY_ID = "development"
+const developmentSecret = "3489453kjhkdayf98di54jk34hksadjfnjkas4h378yfkj3"
+
+// Tokens are signed with the active key and verified with whichever key their kid header names,
+// so a new key can be made active while tokens signed with the old one are still accepted.
+type keyring struct {
+	lock   sync.RWMutex
+	active string
+	keys   map[string][]byte
+}
+
+// Empty until keys are configured, so no token can be signed or verified before then
+var signingKeys = &keyring{keys: map[string][]byte{}}
+
+func ConfigureKeys(active string, keys map[string][]byte) error {
+	if _, ok := keys[active]; !ok {
+		return fmt.Errorf("active signing key %q is not one of the configured keys", active)
+	}
+	for id, secret := range keys {
+		if len(secret) < 32 {
+			return fmt.Errorf("signing key %q must be at least 32 bytes", id)
+		}
+	}
+
+	signingKeys.lock.Lock()
+	defer signingKeys.lock.Unlock()
+	signingKeys.active = active
+	signingKeys.keys = keys
+	return nil
+}
+
+// Signs and verifies tokens with the development key.
+func UseDevelopmentKey() {
+	signingKeys.lock.Lock()
+	defer signingKeys.lock.Unlock()
+	signingKeys.active = DEVELOPMENT_KEY_ID
+	signingKeys.keys = map[string][]byte{DEVELOPMENT_KEY_ID: []byte(developmentSecret)}
+}
+
+func (k *keyring) activeKey() (string, []byte) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.active, k.keys[k.active]
+}
+
+func (k *keyring) key(id string) ([]byte, bool) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	secret, ok := k.keys[id]
+	return secret, ok
+}
@@ -0,0 +1,45 @@
+package player
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"scribl-clone/eventListener"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func getRevokedKey(playerId string) string {
+	return fmt.Sprintf("player/%s/revoked_at", playerId)
+}
+
+// Revokes every token issued to the player so far. The revocation is kept as long as any of those
+// tokens could still be refreshed.
+func RevokePlayerTokens(playerId string) error {
+	rdb := eventListener.GetPubSub()
+	return rdb.Set(
+		context.Background(),
+		getRevokedKey(playerId),
+		time.Now().Unix(),
+		TOKEN_LIFETIME+REFRESH_GRACE,
+	).Err()
+}
+
+func isRevoked(playerId string, issuedAt time.Time) (bool, error) {
+	rdb := eventListener.GetPubSub()
+	raw, err := rdb.Get(context.Background(), getRevokedKey(playerId)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	revokedAt, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Unix() <= revokedAt, nil
+}
@@ -6,8 +6,10 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
@@ -15,20 +17,39 @@ var (
 	ErrInternal     = errors.New("authorization internal error")
 )
 
+const (
+	TOKEN_LIFETIME = 6 * time.Hour
+	// How long after expiring a token can still be exchanged for a new one
+	REFRESH_GRACE = 24 * time.Hour
+)
+
 type PlayerClaim struct {
 	PlayerId string
 	GameId   string
 }
 
-const secret = "3489453kjhkdayf98di54jk34hksadjfnjkas4h378yfkj3"
+type tokenClaims struct {
+	PlayerId string `json:"playerId"`
+	GameId   string `json:"gameId"`
+	jwt.RegisteredClaims
+}
 
 func GenerateToken(claim PlayerClaim) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"playerId": claim.PlayerId,
-		"gameId":   claim.GameId,
+	now := time.Now()
+	keyId, secret := signingKeys.activeKey()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		PlayerId: claim.PlayerId,
+		GameId:   claim.GameId,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TOKEN_LIFETIME)),
+		},
 	})
+	token.Header["kid"] = keyId
 
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := token.SignedString(secret)
 	if err != nil {
 		slog.Error("Error Generating Token")
 	}
@@ -36,31 +57,64 @@ func GenerateToken(claim PlayerClaim) string {
 }
 
 func DecodeToken(stringToken string) (PlayerClaim, error) {
-	token, err := jwt.Parse(stringToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
+	return decodeToken(stringToken, 0)
+}
 
-		return []byte(secret), nil
-	})
+// Issues a new token for the player of a token that is still valid or expired less than
+// REFRESH_GRACE ago.
+func RefreshToken(stringToken string) (PlayerClaim, string, error) {
+	claim, err := decodeToken(stringToken, REFRESH_GRACE)
+	if err != nil {
+		return claim, "", err
+	}
+	return claim, GenerateToken(claim), nil
+}
+
+func signingKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	keyId, _ := token.Header["kid"].(string)
+	secret, ok := signingKeys.key(keyId)
+	if !ok {
+		return nil, jwt.ErrTokenUnverifiable
+	}
+	return secret, nil
+}
+
+func decodeToken(stringToken string, leeway time.Duration) (PlayerClaim, error) {
+	claims := tokenClaims{}
+	token, err := jwt.ParseWithClaims(stringToken, &claims, signingKey,
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+	)
 
 	claim := PlayerClaim{}
 	switch {
 	case token != nil && token.Valid:
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			if playerId, ok := claims["playerId"].(string); ok {
-				claim.PlayerId = playerId
-			}
-			if gameId, ok := claims["gameId"].(string); ok {
-				claim.GameId = gameId
-			}
-			return claim, nil
+		claim.PlayerId = claims.PlayerId
+		claim.GameId = claims.GameId
+
+		revoked, err := isRevoked(claim.PlayerId, claims.IssuedAt.Time)
+		if err != nil {
+			slog.Error(err.Error())
+			return claim, ErrInternal
+		}
+		if revoked {
+			return claim, ErrUnauthorized
 		}
+		return claim, nil
 	case errors.Is(err, jwt.ErrTokenMalformed):
 		return claim, ErrUnauthorized
 	case errors.Is(err, jwt.ErrTokenSignatureInvalid):
 		return claim, ErrUnauthorized
-	case errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet):
+	case errors.Is(err, jwt.ErrTokenUnverifiable):
+		return claim, ErrUnauthorized
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return claim, ErrUnauthorized
+	case errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet) || errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
 		return claim, ErrUnauthorized
 	default:
 		slog.Error(err.Error())
@@ -68,8 +122,30 @@ func DecodeToken(stringToken string) (PlayerClaim, error) {
 	return claim, ErrInternal
 }
 
+func GetBearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// Returns the token in the token query parameter, or the Authorization header when there is none.
+// Browsers can't set headers on websockets and event streams, so they send it in the query.
+func GetRequestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return GetBearerToken(r)
+}
+
+// Returns who sent the request without checking whether their token was revoked, which is enough
+// to label logs but not to authorize anything.
+func IdentifyRequest(r *http.Request) (PlayerClaim, bool) {
+	claims := tokenClaims{}
+	token, err := jwt.ParseWithClaims(GetBearerToken(r), &claims, signingKey, jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return PlayerClaim{}, false
+	}
+	return PlayerClaim{PlayerId: claims.PlayerId, GameId: claims.GameId}, true
+}
+
 func AuthorizeRequest(r *http.Request) (PlayerClaim, error) {
-	Token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-	slog.Debug(Token)
-	return DecodeToken(Token)
+	return DecodeToken(GetBearerToken(r))
 }
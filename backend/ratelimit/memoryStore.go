@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const PRUNE_INTERVAL = time.Minute
+
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+// Keeps counts in the memory of this process, limits aren't shared between replicas.
+type MemoryStore struct {
+	lock      sync.Mutex
+	windows   map[string]*window
+	lastPrune time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		windows:   make(map[string]*window),
+		lastPrune: time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) > PRUNE_INTERVAL {
+		s.prune(now)
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(limit.Window)}
+		s.windows[key] = w
+	}
+	if w.count >= limit.Requests {
+		return false, w.resetAt.Sub(now), nil
+	}
+	w.count++
+	return true, 0, nil
+}
+
+func (s *MemoryStore) prune(now time.Time) {
+	for key, w := range s.windows {
+		if !now.Before(w.resetAt) {
+			delete(s.windows, key)
+		}
+	}
+	s.lastPrune = now
+}
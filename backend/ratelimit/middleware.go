@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"scribl-clone/player"
+	"strconv"
+)
+
+type Limiter struct {
+	store Store
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{store: store}
+}
+
+// Limits a route. Requests are counted per player when they carry a valid token, otherwise per
+// IP. Relies on middleware.RealIP having set RemoteAddr. Requests over the limit get a 429 with a
+// Retry-After header.
+func (l *Limiter) Limit(name string, limit Limit) func(http.Handler) http.Handler {
+	return l.limit(name, limit, clientKey)
+}
+
+// Limits a route per IP, whatever token the request carries. Meant for routes that hand out
+// tokens, where counting per player would let a client start a fresh count with each new token.
+func (l *Limiter) LimitPerIP(name string, limit Limit) func(http.Handler) http.Handler {
+	return l.limit(name, limit, ipKey)
+}
+
+func (l *Limiter) limit(name string, limit Limit, keyOf func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := name + "/" + keyOf(r)
+
+			allowed, retryAfter, err := l.store.Take(r.Context(), key, limit)
+			if err != nil {
+				// Don't lock everyone out when the store is down
+				slog.Error("Error checking rate limit", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if r.Header.Get("Authorization") != "" {
+		if claim, err := player.AuthorizeRequest(r); err == nil {
+			return "player/" + claim.PlayerId
+		}
+	}
+
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// RealIP sets RemoteAddr without a port
+		ip = r.RemoteAddr
+	}
+	return "ip/" + ip
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"scribl-clone/player"
+	"testing"
+	"time"
+)
+
+// A store that is down.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit) (bool, time.Duration, error) {
+	return false, 0, errors.New("store is down")
+}
+
+type testRequest struct {
+	remoteAddr string
+	// Sent as a bearer token when set
+	token string
+}
+
+func TestMiddleware(t *testing.T) {
+	alice := player.GenerateToken(player.PlayerClaim{PlayerId: "alice", GameId: "game"})
+	bob := player.GenerateToken(player.PlayerClaim{PlayerId: "bob", GameId: "game"})
+	limit := Limit{Requests: 2, Window: time.Minute}
+
+	tests := []struct {
+		name     string
+		perIP    bool
+		requests []testRequest
+		statuses []int
+	}{
+		{
+			"counted per ip without a token",
+			false,
+			[]testRequest{{"1.1.1.1:1000", ""}, {"1.1.1.1:2000", ""}, {"1.1.1.1:1000", ""}, {"2.2.2.2:1000", ""}},
+			[]int{200, 200, 429, 200},
+		},
+		{
+			"remote address without a port",
+			false,
+			[]testRequest{{"1.1.1.1", ""}, {"1.1.1.1", ""}, {"1.1.1.1", ""}},
+			[]int{200, 200, 429},
+		},
+		{
+			"counted per player with a token",
+			false,
+			[]testRequest{{"1.1.1.1:1000", alice}, {"1.1.1.1:1000", alice}, {"1.1.1.1:1000", bob}, {"1.1.1.1:1000", alice}},
+			[]int{200, 200, 200, 429},
+		},
+		{
+			"invalid token is counted per ip",
+			false,
+			[]testRequest{{"1.1.1.1:1000", "invalid"}, {"1.1.1.1:1000", "invalid"}, {"1.1.1.1:1000", ""}},
+			[]int{200, 200, 429},
+		},
+		{
+			"per ip ignores tokens",
+			true,
+			[]testRequest{{"1.1.1.1:1000", alice}, {"1.1.1.1:1000", bob}, {"1.1.1.1:1000", ""}, {"2.2.2.2:1000", alice}},
+			[]int{200, 200, 429, 200},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(NewMemoryStore())
+			middleware := limiter.Limit("route", limit)
+			if tt.perIP {
+				middleware = limiter.LimitPerIP("route", limit)
+			}
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, request := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				r.RemoteAddr = request.remoteAddr
+				if request.token != "" {
+					r.Header.Set("Authorization", "Bearer "+request.token)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != tt.statuses[i] {
+					t.Errorf("request %d status = %d, want %d", i, w.Code, tt.statuses[i])
+				}
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d was limited without a Retry-After header", i)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareAllowsWhenStoreFails(t *testing.T) {
+	handler := New(failingStore{}).Limit("route", Limit{Requests: 1, Window: time.Minute})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := range 3 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("request %d status = %d, want 200", i, w.Code)
+		}
+	}
+}
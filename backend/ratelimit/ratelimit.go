@@ -0,0 +1,20 @@
+package ratelimit
+
+// This module limits how often a client can call an endpoint
+
+import (
+	"context"
+	"time"
+)
+
+// Allows Requests in every Window. Windows are fixed, they start at the first request.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+type Store interface {
+	// Counts a request against the key, returning whether it is allowed and, when it isn't, how
+	// long until the next request would be.
+	Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
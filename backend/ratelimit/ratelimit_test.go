@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"scribl-clone/config"
+	"scribl-clone/eventListener"
+	"scribl-clone/player"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	testRedis = mr
+	// Tokens are checked for revocation in redis
+	eventListener.Connect(config.RedisConfig{Addr: mr.Addr()})
+	player.UseDevelopmentKey()
+
+	code := m.Run()
+	eventListener.Close()
+	mr.Close()
+	os.Exit(code)
+}
+
+func TestTake(t *testing.T) {
+	stores := map[string]func() Store{
+		"memory": func() Store { return NewMemoryStore() },
+		"redis":  func() Store { return NewRedisStore(eventListener.GetPubSub()) },
+	}
+	limit := Limit{Requests: 3, Window: time.Minute}
+
+	tests := []struct {
+		name string
+		keys []string
+		// Whether each take is allowed
+		allowed []bool
+	}{
+		{"under the limit", []string{"a", "a"}, []bool{true, true}},
+		{"at the limit", []string{"a", "a", "a"}, []bool{true, true, true}},
+		{"over the limit", []string{"a", "a", "a", "a", "a"}, []bool{true, true, true, false, false}},
+		{"keys are counted apart", []string{"a", "a", "a", "b", "a", "b"}, []bool{true, true, true, true, false, true}},
+	}
+	for storeName, newStore := range stores {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				t.Cleanup(testRedis.FlushAll)
+				store := newStore()
+				for i, key := range tt.keys {
+					allowed, retryAfter, err := store.Take(context.Background(), key, limit)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if allowed != tt.allowed[i] {
+						t.Errorf("take %d of %q allowed = %v, want %v", i, key, allowed, tt.allowed[i])
+					}
+					if !allowed && (retryAfter <= 0 || retryAfter > limit.Window) {
+						t.Errorf("take %d of %q retry after %v, want within the window", i, key, retryAfter)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestTakeAfterWindow(t *testing.T) {
+	t.Cleanup(testRedis.FlushAll)
+	const window = 50 * time.Millisecond
+	limit := Limit{Requests: 1, Window: window}
+
+	stores := map[string]struct {
+		store Store
+		// Lets the window pass
+		wait func()
+	}{
+		"memory": {NewMemoryStore(), func() { time.Sleep(window) }},
+		"redis":  {NewRedisStore(eventListener.GetPubSub()), func() { testRedis.FastForward(window) }},
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if allowed, _, _ := s.store.Take(ctx, "key", limit); !allowed {
+				t.Fatal("first take wasn't allowed")
+			}
+			if allowed, _, _ := s.store.Take(ctx, "key", limit); allowed {
+				t.Fatal("second take in the window was allowed")
+			}
+			s.wait()
+			if allowed, _, _ := s.store.Take(ctx, "key", limit); !allowed {
+				t.Error("take after the window wasn't allowed")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Keeps counts in redis so every replica shares the same limits.
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+// Increments the count and starts the window on the first request, atomically.
+var takeScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	result, err := takeScript.Run(ctx, s.rdb, []string{"ratelimit/" + key}, limit.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	count, ttl := result[0], time.Duration(result[1])*time.Millisecond
+	if count > int64(limit.Requests) {
+		return false, max(ttl, 0), nil
+	}
+	return true, 0, nil
+}
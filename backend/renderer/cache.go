@@ -0,0 +1,55 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"scribl-clone/eventListener"
+	"scribl-clone/replay"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Timelapses of finished turns never change, so they are kept as long as the recording they were
+// made from
+const TIMELAPSE_CACHE_TTL = replay.REPLAY_TTL
+
+// Thumbnails are only made of finished turns, which never change
+const THUMBNAIL_CACHE_TTL = 24 * time.Hour
+
+func getTimelapseKey(gameId string, turn int, fps int, opts Options) string {
+	return fmt.Sprintf("game/%s/turns/%d/timelapse/%d/%dx%d", gameId, turn, fps, opts.Width, opts.Height)
+}
+
+// Returns a timelapse GIF stored by CacheTimelapse, or false if there is none.
+func CachedTimelapse(gameId string, turn int, fps int, opts Options) ([]byte, bool, error) {
+	encoded, err := eventListener.GetPubSub().Get(context.Background(), getTimelapseKey(gameId, turn, fps, opts)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return encoded, err == nil, err
+}
+
+// Stores the timelapse GIF of a finished turn.
+func CacheTimelapse(gameId string, turn int, fps int, opts Options, encoded []byte) error {
+	return eventListener.GetPubSub().Set(context.Background(), getTimelapseKey(gameId, turn, fps, opts), encoded, TIMELAPSE_CACHE_TTL).Err()
+}
+
+func getThumbnailKey(gameId string, turn int, opts Options) string {
+	return fmt.Sprintf("game/%s/turns/%d/thumbnail/%dx%d", gameId, turn, opts.Width, opts.Height)
+}
+
+// Returns a PNG thumbnail stored by CacheThumbnail, or false if there is none.
+func CachedThumbnail(gameId string, turn int, opts Options) ([]byte, bool, error) {
+	encoded, err := eventListener.GetPubSub().Get(context.Background(), getThumbnailKey(gameId, turn, opts)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return encoded, err == nil, err
+}
+
+// Stores the PNG thumbnail of a finished turn.
+func CacheThumbnail(gameId string, turn int, opts Options, encoded []byte) error {
+	return eventListener.GetPubSub().Set(context.Background(), getThumbnailKey(gameId, turn, opts), encoded, THUMBNAIL_CACHE_TTL).Err()
+}
@@ -0,0 +1,111 @@
+package renderer
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"scribl-clone/game"
+	"scribl-clone/utils"
+	"slices"
+)
+
+// An image kept in step with a drawing as it is made, so each frame of an animation only draws
+// what changed since the last one.
+type canvas struct {
+	img   *image.RGBA
+	lines []game.Line
+	opts  Options
+}
+
+func newCanvas(opts Options) *canvas {
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{BACKGROUND_COLOR}, image.Point{}, draw.Src)
+	return &canvas{img: img, opts: opts}
+}
+
+// Brings the canvas up to date with lines and returns the region that changed. Lines that only
+// gained points since the last update have just their new segments drawn, as long as nothing
+// drawn later sits on top of them. Anything else redraws the whole canvas.
+func (c *canvas) update(lines []game.Line) image.Rectangle {
+	incremental := len(lines) >= len(c.lines)
+	firstChanged := -1
+	for i := 0; incremental && i < len(lines); i++ {
+		old := c.previousLine(i)
+		if sameLine(old, lines[i]) {
+			if firstChanged != -1 && len(lines[i].Points) > 0 {
+				incremental = false
+			}
+			continue
+		}
+		if !extendsLine(old, lines[i]) {
+			incremental = false
+		}
+		if firstChanged == -1 {
+			firstChanged = i
+		}
+	}
+
+	if !incremental {
+		c.lines = lines
+		draw.Draw(c.img, c.img.Bounds(), &image.Uniform{BACKGROUND_COLOR}, image.Point{}, draw.Src)
+		for _, line := range lines {
+			drawLine(c.img, line, 0, c.opts)
+		}
+		return c.img.Bounds()
+	}
+
+	changed := image.Rectangle{}
+	for i := max(firstChanged, 0); firstChanged != -1 && i < len(lines); i++ {
+		old := c.previousLine(i)
+		if !sameLine(old, lines[i]) {
+			changed = changed.Union(drawLine(c.img, lines[i], len(old.Points), c.opts))
+		}
+	}
+	c.lines = lines
+	return changed
+}
+
+func (c *canvas) previousLine(i int) game.Line {
+	if i < len(c.lines) {
+		return c.lines[i]
+	}
+	return game.Line{}
+}
+
+func sameLine(a game.Line, b game.Line) bool {
+	return a.Size == b.Size && a.Rgb == b.Rgb && slices.Equal(a.Points, b.Points)
+}
+
+// Reports whether b is a with more points added to its end.
+func extendsLine(a game.Line, b game.Line) bool {
+	if len(a.Points) == 0 {
+		return true
+	}
+	return a.Size == b.Size && a.Rgb == b.Rgb &&
+		len(b.Points) >= len(a.Points) && slices.Equal(a.Points, b.Points[:len(a.Points)])
+}
+
+// Draws the segments of the line that end at or after point from, returning the region drawn on.
+func drawLine(img *image.RGBA, line game.Line, from int, opts Options) image.Rectangle {
+	drawn := image.Rectangle{}
+	if len(line.Points) == 0 {
+		return drawn
+	}
+	scaleX, scaleY := opts.scale()
+	radius := math.Max(float64(line.Size)*math.Min(scaleX, scaleY)/2, 0.5)
+	c := lineColor(line)
+
+	for i := from; i < len(line.Points); i++ {
+		// A line with a single point is drawn as a dot
+		start := line.Points[utils.If(i == 0, 0, i-1)]
+		end := line.Points[i]
+		drawn = drawn.Union(strokeSegment(
+			img,
+			float64(start.X)*scaleX, float64(start.Y)*scaleY,
+			float64(end.X)*scaleX, float64(end.Y)*scaleY,
+			radius,
+			c,
+		))
+	}
+	return drawn
+}
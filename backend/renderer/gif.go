@@ -0,0 +1,71 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"scribl-clone/game"
+	"time"
+)
+
+type Frame struct {
+	Lines []game.Line
+	Delay time.Duration
+}
+
+// Encodes the frames as a looping animated GIF. After the first frame only the region that
+// changed is stored, which keeps long animations small.
+func RenderGIF(w io.Writer, frames []Frame, opts Options) error {
+	pal := buildPalette(frames)
+	anim := gif.GIF{
+		Config: image.Config{ColorModel: pal, Width: opts.Width, Height: opts.Height},
+	}
+
+	canvas := newCanvas(opts)
+	for i, frame := range frames {
+		bounds := canvas.update(frame.Lines)
+		if i == 0 {
+			bounds = canvas.img.Bounds()
+		}
+
+		if bounds.Empty() {
+			anim.Delay[len(anim.Delay)-1] += toGIFDelay(frame.Delay)
+			continue
+		}
+
+		paletted := image.NewPaletted(bounds, pal)
+		draw.Draw(paletted, bounds, canvas.img, bounds.Min, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, toGIFDelay(frame.Delay))
+		anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+	}
+	return gif.EncodeAll(w, &anim)
+}
+
+// GIF delays are in hundredths of a second.
+func toGIFDelay(d time.Duration) int {
+	return max(int(d/(10*time.Millisecond)), 1)
+}
+
+// Uses the exact colours of the drawing when they fit in a GIF palette.
+func buildPalette(frames []Frame) color.Palette {
+	seen := map[color.RGBA]bool{BACKGROUND_COLOR: true}
+	pal := color.Palette{BACKGROUND_COLOR}
+	for _, frame := range frames {
+		for _, line := range frame.Lines {
+			c := lineColor(line)
+			if seen[c] {
+				continue
+			}
+			if len(pal) == 256 {
+				return palette.Plan9
+			}
+			seen[c] = true
+			pal = append(pal, c)
+		}
+	}
+	return pal
+}
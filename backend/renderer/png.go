@@ -0,0 +1,52 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"scribl-clone/game"
+)
+
+// Draws the lines onto a new image, lines drawn later are painted over earlier ones.
+func Rasterise(lines []game.Line, opts Options) *image.RGBA {
+	c := newCanvas(opts)
+	c.update(lines)
+	return c.img
+}
+
+func RenderPNG(w io.Writer, lines []game.Line, opts Options) error {
+	return png.Encode(w, Rasterise(lines, opts))
+}
+
+// Fills every pixel whose centre is within radius of the segment, giving round caps and joins.
+// Returns the region that was drawn on.
+func strokeSegment(img *image.RGBA, x0, y0, x1, y1, radius float64, c color.RGBA) image.Rectangle {
+	bounds := image.Rect(
+		int(math.Floor(math.Min(x0, x1)-radius)),
+		int(math.Floor(math.Min(y0, y1)-radius)),
+		int(math.Ceil(math.Max(x0, x1)+radius))+1,
+		int(math.Ceil(math.Max(y0, y1)+radius))+1,
+	).Intersect(img.Bounds())
+
+	dx, dy := x1-x0, y1-y0
+	lengthSq := dx*dx + dy*dy
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+
+			// Project the pixel onto the segment to find the closest point
+			t := 0.0
+			if lengthSq > 0 {
+				t = math.Max(0, math.Min(1, ((px-x0)*dx+(py-y0)*dy)/lengthSq))
+			}
+			cx, cy := x0+t*dx, y0+t*dy
+			if (px-cx)*(px-cx)+(py-cy)*(py-cy) <= radius*radius {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return bounds
+}
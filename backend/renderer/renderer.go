@@ -0,0 +1,37 @@
+package renderer
+
+// This module turns the lines of a drawing into images using only the standard library
+
+import (
+	"image/color"
+	"scribl-clone/game"
+)
+
+// Size of the canvas the clients draw on, points are given in this coordinate space.
+const (
+	CANVAS_WIDTH  = 780
+	CANVAS_HEIGHT = 780
+)
+
+var BACKGROUND_COLOR = color.RGBA{255, 255, 255, 255}
+
+type Options struct {
+	Width  int
+	Height int
+}
+
+func DefaultOptions() Options {
+	return Options{Width: CANVAS_WIDTH, Height: CANVAS_HEIGHT}
+}
+
+func (o Options) scale() (float64, float64) {
+	return float64(o.Width) / CANVAS_WIDTH, float64(o.Height) / CANVAS_HEIGHT
+}
+
+func lineColor(line game.Line) color.RGBA {
+	return color.RGBA{clampChannel(line.Rgb[0]), clampChannel(line.Rgb[1]), clampChannel(line.Rgb[2]), 255}
+}
+
+func clampChannel(c int) uint8 {
+	return uint8(min(max(c, 0), 255))
+}
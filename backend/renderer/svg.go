@@ -0,0 +1,51 @@
+package renderer
+
+import (
+	"fmt"
+	"io"
+	"scribl-clone/game"
+	"strconv"
+	"strings"
+)
+
+func RenderSVG(w io.Writer, lines []game.Line, opts Options) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		opts.Width, opts.Height, CANVAS_WIDTH, CANVAS_HEIGHT,
+	)
+	fmt.Fprintf(&b,
+		`<rect width="100%%" height="100%%" fill="rgb(%d,%d,%d)"/>`,
+		BACKGROUND_COLOR.R, BACKGROUND_COLOR.G, BACKGROUND_COLOR.B,
+	)
+
+	for _, line := range lines {
+		if len(line.Points) == 0 {
+			continue
+		}
+		c := lineColor(line)
+
+		points := make([]string, 0, len(line.Points)+1)
+		for _, p := range line.Points {
+			points = append(points, formatFloat(p.X)+","+formatFloat(p.Y))
+		}
+		if len(points) == 1 {
+			// A polyline needs two points to be stroked
+			points = append(points, points[0])
+		}
+
+		fmt.Fprintf(&b,
+			`<polyline points="%s" fill="none" stroke="rgb(%d,%d,%d)" stroke-width="%d" stroke-linecap="round" stroke-linejoin="round"/>`,
+			strings.Join(points, " "), c.R, c.G, c.B, max(line.Size, 1),
+		)
+	}
+	b.WriteString(`</svg>`)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
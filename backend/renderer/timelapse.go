@@ -0,0 +1,129 @@
+package renderer
+
+import (
+	"encoding/json"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/replay"
+	"slices"
+	"time"
+)
+
+const (
+	DEFAULT_TIMELAPSE_FPS = 10
+	MAX_TIMELAPSE_FPS     = 30
+	MAX_TIMELAPSE_FRAMES  = 300
+	// Largest width or height of a timelapse, every frame is drawn at this size
+	MAX_TIMELAPSE_SIZE = 1024
+	TIMELAPSE_END_HOLD = 2 * time.Second
+
+	// Guards against a client sending an absurd line index
+	MAX_LINE_INDEX = 10_000
+)
+
+type upsert struct {
+	time  time.Time
+	index int
+	line  game.Line
+}
+
+// Builds the frames showing how a turn's drawing was made from the recorded drawing events.
+// Frames are taken fps times a second of the original turn, long turns are sped up so they fit in
+// MAX_TIMELAPSE_FRAMES. Also reports whether the turn is over, the frames of a turn still being
+// drawn will change.
+func Timelapse(rep *replay.Replay, turn int, fps int) ([]Frame, bool, error) {
+	upserts, found, finished, err := turnUpserts(rep, turn)
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		return nil, false, game.ErrTurnNotFound
+	}
+	if len(upserts) == 0 {
+		return []Frame{{Delay: TIMELAPSE_END_HOLD}}, finished, nil
+	}
+
+	fps = min(max(fps, 1), MAX_TIMELAPSE_FPS)
+	interval := time.Second / time.Duration(fps)
+	start, end := upserts[0].time, upserts[len(upserts)-1].time
+	if end.Sub(start)/interval > MAX_TIMELAPSE_FRAMES {
+		interval = end.Sub(start) / MAX_TIMELAPSE_FRAMES
+	}
+
+	frames := []Frame{}
+	lines := []game.Line{}
+	next := 0
+	for t := start; next < len(upserts); t = t.Add(interval) {
+		applied := false
+		for ; next < len(upserts) && !upserts[next].time.After(t); next++ {
+			lines = applyUpsert(lines, upserts[next])
+			applied = true
+		}
+		if !applied {
+			frames[len(frames)-1].Delay += interval
+			continue
+		}
+		frames = append(frames, Frame{Lines: slices.Clone(lines), Delay: interval})
+	}
+	frames[len(frames)-1].Delay += TIMELAPSE_END_HOLD
+	return frames, finished, nil
+}
+
+func applyUpsert(lines []game.Line, u upsert) []game.Line {
+	if u.index < 0 || u.index >= MAX_LINE_INDEX {
+		return lines
+	}
+	for len(lines) <= u.index {
+		lines = append(lines, game.Line{})
+	}
+	lines[u.index] = u.line
+	return lines
+}
+
+// Collects the drawing events of a turn. Turns are counted by the game updates that move the game
+// into the drawing state, the same way game.SelectWord numbers them. The turn is finished once the
+// game leaves the drawing state.
+func turnUpserts(rep *replay.Replay, turn int) (upserts []upsert, found bool, finished bool, err error) {
+	upserts = []upsert{}
+	currentTurn := 0
+
+	for _, record := range rep.Events {
+		event := struct {
+			EventType    int
+			EventPayload json.RawMessage
+		}{}
+		if err := json.Unmarshal(record.Event, &event); err != nil {
+			return nil, false, false, err
+		}
+
+		switch event.EventType {
+		case game.GAME_EVENT_GAME_UPDATE:
+			update := struct {
+				State *int `json:"state"`
+			}{}
+			if err := json.Unmarshal(event.EventPayload, &update); err != nil {
+				return nil, false, false, err
+			}
+			if update.State != nil && *update.State == data.GAME_STATE_DRAWING {
+				currentTurn++
+			} else if update.State != nil && currentTurn == turn {
+				finished = true
+			}
+		case game.GAME_EVENT_DRAWING:
+			if currentTurn != turn {
+				continue
+			}
+			payload := game.DrawingEventPayload{}
+			if err := json.Unmarshal(event.EventPayload, &payload); err != nil {
+				return nil, false, false, err
+			}
+			upserts = append(upserts, upsert{time: record.Time, index: payload.Index, line: payload.Line})
+		}
+
+		if currentTurn > turn || finished {
+			break
+		}
+	}
+	found = currentTurn >= turn && turn > 0
+	return upserts, found, finished || currentTurn > turn, nil
+}
@@ -0,0 +1,26 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Writes the replay as a single JSON document.
+func (r *Replay) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// Writes the replay as newline delimited JSON, the header on the first line followed by one
+// record per line.
+func (r *Replay) WriteNDJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(r.Header); err != nil {
+		return err
+	}
+	for _, record := range r.Events {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
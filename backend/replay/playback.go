@@ -0,0 +1,34 @@
+package replay
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	MIN_PLAYBACK_SPEED = 0.25
+	MAX_PLAYBACK_SPEED = 16
+)
+
+// Calls emit with each recorded event, waiting between events as long as the original game did,
+// divided by speed. Stops early when the context is cancelled or emit fails.
+func (r *Replay) Play(ctx context.Context, speed float64, emit func(event []byte) error) error {
+	speed = min(max(speed, MIN_PLAYBACK_SPEED), MAX_PLAYBACK_SPEED)
+
+	for i, record := range r.Events {
+		if i > 0 {
+			gap := record.Time.Sub(r.Events[i-1].Time)
+			timer := time.NewTimer(time.Duration(float64(gap) / speed))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if err := emit(record.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package replay
+
+// This module records the events published to a game so the game can be downloaded and played back
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"scribl-clone/eventListener"
+	"time"
+)
+
+const (
+	FORMAT_VERSION = 1
+	REPLAY_TTL     = 7 * 24 * time.Hour
+)
+
+// A single recorded event. Event is exactly what was published to the game channel.
+type Record struct {
+	Time  time.Time       `json:"time"`
+	Event json.RawMessage `json:"event"`
+}
+
+type Header struct {
+	Version   int       `json:"version"`
+	GameId    string    `json:"gameId"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+}
+
+type Replay struct {
+	Header
+	Events []Record `json:"events"`
+}
+
+func getReplayKey(gameId string) string {
+	return fmt.Sprintf("game/%s/replay", gameId)
+}
+
+// Appends an event to the game's recording, returning its sequence number. Events are numbered
+// from 1 in the order they were recorded.
+func RecordEvent(gameId string, event []byte) (int64, error) {
+	encoded, err := json.Marshal(Record{
+		Time:  time.Now().UTC(),
+		Event: event,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	rdb := eventListener.GetPubSub()
+	ctx := context.Background()
+	key := getReplayKey(gameId)
+	seq, err := rdb.RPush(ctx, key, encoded).Result()
+	if err != nil {
+		return 0, err
+	}
+	return seq, rdb.Expire(ctx, key, REPLAY_TTL).Err()
+}
+
+// Returns the events recorded after the one numbered seq.
+func Since(gameId string, seq int64) ([]Record, error) {
+	rdb := eventListener.GetPubSub()
+	raw, err := rdb.LRange(context.Background(), getReplayKey(gameId), seq, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(raw))
+	for _, r := range raw {
+		record := Record{}
+		if err := json.Unmarshal([]byte(r), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Loads everything recorded for a game. A game with no recorded events has an empty replay.
+func Load(gameId string) (*Replay, error) {
+	rdb := eventListener.GetPubSub()
+	raw, err := rdb.LRange(context.Background(), getReplayKey(gameId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	replay := Replay{
+		Header: Header{Version: FORMAT_VERSION, GameId: gameId},
+		Events: make([]Record, 0, len(raw)),
+	}
+	for _, r := range raw {
+		record := Record{}
+		if err := json.Unmarshal([]byte(r), &record); err != nil {
+			return nil, err
+		}
+		replay.Events = append(replay.Events, record)
+	}
+	if len(replay.Events) > 0 {
+		replay.StartedAt = replay.Events[0].Time
+		replay.EndedAt = replay.Events[len(replay.Events)-1].Time
+	}
+	return &replay, nil
+}
@@ -0,0 +1,165 @@
+package sockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"scribl-clone/game"
+	"scribl-clone/guess"
+	"scribl-clone/logging"
+	"scribl-clone/moderation"
+	"scribl-clone/tracing"
+	"scribl-clone/utils"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Commands clients can send over the websocket, each mirrors an HTTP endpoint.
+const (
+	COMMAND_GUESS       = "guess"
+	COMMAND_CHAT        = "chat"
+	COMMAND_SELECT_WORD = "select_word"
+	COMMAND_READY       = "ready"
+	COMMAND_DRAW        = "draw"
+)
+
+type command struct {
+	Command string          `json:"command"`
+	Id      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type commandReply struct {
+	Kind    string `json:"kind"`
+	Id      string `json:"id"`
+	Message string `json:"message,omitempty"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+type guessResult struct {
+	Close   bool `json:"close"`
+	Correct bool `json:"correct"`
+}
+
+// Handles a message sent by a player. Messages with a command are answered with an ack or an
+// error carrying the command's id. Older clients send drawing events in the same format as the
+// events they receive, those are still accepted.
+func handleClientMessage(conn *Connection, gameId string, message string) {
+	cmd := command{}
+	if err := json.Unmarshal([]byte(message), &cmd); err != nil {
+		slog.ErrorContext(conn.ctx, "Error decoding websocket message", "error", err)
+		return
+	}
+	if cmd.Command == "" {
+		handleLegacyEvent(conn.ctx, gameId, conn.playerId, message)
+		return
+	}
+
+	ctx, span := tracing.Start(logging.With(conn.ctx, "command", cmd.Command, "commandId", cmd.Id), "websocket "+cmd.Command)
+	defer span.End()
+
+	result, err := runCommand(gameId, conn.playerId, cmd)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		conn.writeJSON(commandReply{Kind: "error", Id: cmd.Id, Message: errorMessage(ctx, err)})
+		return
+	}
+	conn.writeJSON(commandReply{Kind: "ack", Id: cmd.Id, Payload: result})
+}
+
+func runCommand(gameId string, playerId string, cmd command) (any, error) {
+	switch cmd.Command {
+	case COMMAND_GUESS:
+		body := struct {
+			Guess string `json:"guess"`
+		}{}
+		if err := decodePayload(cmd, &body); err != nil {
+			return nil, err
+		}
+		result, err := game.SubmitGuess(gameId, playerId, body.Guess)
+		return toGuessResult(result), err
+	case COMMAND_CHAT:
+		body := struct {
+			Message string `json:"message"`
+		}{}
+		if err := decodePayload(cmd, &body); err != nil {
+			return nil, err
+		}
+		result, err := game.SendChat(gameId, playerId, body.Message)
+		return toGuessResult(result), err
+	case COMMAND_SELECT_WORD:
+		body := struct {
+			Word string `json:"word"`
+		}{}
+		if err := decodePayload(cmd, &body); err != nil {
+			return nil, err
+		}
+		return nil, game.ChooseWord(gameId, playerId, body.Word)
+	case COMMAND_READY:
+		return nil, game.MarkReady(gameId, playerId)
+	case COMMAND_DRAW:
+		body := game.DrawingEventPayload{}
+		if err := decodePayload(cmd, &body); err != nil {
+			return nil, err
+		}
+		return nil, game.Draw(gameId, playerId, body.Line, body.Index)
+	}
+	return nil, utils.ErrInvalidArguments
+}
+
+func handleLegacyEvent(ctx context.Context, gameId string, playerId string, message string) {
+	gameEvent := game.GameEvent{}
+	if err := json.Unmarshal([]byte(message), &gameEvent); err != nil {
+		slog.ErrorContext(ctx, "Error decoding websocket connection", "error", err)
+		return
+	}
+	if gameEvent.EventType != game.GAME_EVENT_DRAWING {
+		return
+	}
+
+	drawingEvent := struct {
+		game.GameEvent
+		EventPayload game.DrawingEventPayload
+	}{}
+	if err := json.Unmarshal([]byte(message), &drawingEvent); err != nil {
+		slog.ErrorContext(ctx, "Error decoding drawing event", "error", err)
+		return
+	}
+	if err := game.Draw(gameId, playerId, drawingEvent.EventPayload.Line, drawingEvent.EventPayload.Index); err != nil {
+		slog.DebugContext(ctx, "Rejected drawing event", "error", err)
+	}
+}
+
+func decodePayload(cmd command, v any) error {
+	if len(cmd.Payload) == 0 || string(cmd.Payload) == "null" {
+		return utils.ErrInvalidArguments
+	}
+	if err := json.Unmarshal(cmd.Payload, v); err != nil {
+		return utils.ErrInvalidArguments
+	}
+	return nil
+}
+
+func toGuessResult(result guess.Result) guessResult {
+	return guessResult{
+		Close:   result == guess.RESULT_CLOSE,
+		Correct: result.IsCorrect(),
+	}
+}
+
+// Gives the same messages as the HTTP endpoints, without exposing internal errors.
+func errorMessage(ctx context.Context, err error) string {
+	var gameErr *game.GameError
+	switch {
+	case errors.As(err, &gameErr):
+		return gameErr.Message
+	case errors.Is(err, moderation.ErrRejected),
+		errors.Is(err, utils.ErrInvalidArguments),
+		errors.Is(err, utils.ErrResourceNotFound),
+		errors.Is(err, utils.ErrTooManyRequests):
+		return err.Error()
+	}
+	slog.ErrorContext(ctx, err.Error())
+	return "internal error"
+}
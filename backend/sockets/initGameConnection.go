@@ -2,18 +2,21 @@ package sockets
 
 import (
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"scribl-clone/data"
 	"scribl-clone/game"
+	"scribl-clone/player"
 	"scribl-clone/utils"
+	"time"
 
-	"github.com/go-chi/chi"
 	"github.com/gorilla/websocket"
 )
 
+// How long a client that didn't send its token with the request has to send it as a message
+const AUTH_TIMEOUT = 10 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -21,57 +24,108 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// Players authenticate with their token, in the token query parameter, the Authorization header
+// or, for clients that would rather keep it out of the URL, as the first message: {"token": "..."}.
+// The player and game are taken from the token, the id in the path is only kept for older clients.
 func InitGameConnection(w http.ResponseWriter, r *http.Request) {
-	userId := chi.URLParam(r, "userId")
-	db := data.GetDb()
+	token := player.GetRequestToken(r)
 
-	p := data.Player{}
-	err := db.Get(&p, `SELECT game FROM player WHERE id = $1`, userId)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.NotFound(w, r)
+	var claim player.PlayerClaim
+	var err error
+	if token != "" {
+		if claim, err = authorizePlayer(token); err != nil {
+			utils.HandleError(w, r, err)
 			return
 		}
-		utils.HandleError(w, err)
-		return
 	}
 
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		utils.HandleError(w, err)
+		utils.HandleError(w, r, err)
 		return
 	}
 
-	ws.WriteJSON(struct {
-		Kind    string `json:"kind"`
-		Message string `json:"message"`
-	}{
-		Kind:    "init-connection",
-		Message: "successfully connected",
-	})
+	if token == "" {
+		if claim, err = readAuthMessage(ws); err != nil {
+			slog.DebugContext(r.Context(), "Rejected websocket authentication", "error", err)
+			ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "unauthorized"),
+				time.Now().Add(AUTH_TIMEOUT),
+			)
+			ws.Close()
+			return
+		}
+	}
 
-	channelName := game.GetGameChannelName(p.Game)
-	conn, err := CreateConnection(channelName, userId, ws)
+	channelName := game.GetGameChannelName(claim.GameId)
+	_, err = CreateConnection(channelName, claim.PlayerId, claim.PlayerId, ws, func(conn *Connection, message string) {
+		handleClientMessage(conn, claim.GameId, message)
+	})
 	if err != nil {
-		utils.HandleError(w, err)
+		slog.ErrorContext(r.Context(), "Error creating connection", "error", err)
+		ws.Close()
 		return
 	}
-	conn.SetCallback(func(s string) {
-		gameEvent := game.GameEvent{}
-		err := json.Unmarshal([]byte(s), &gameEvent)
-		if err != nil {
-			slog.Error("Error decoding websocket connection", "error", err)
-		}
-		if gameEvent.EventType == game.GAME_EVENT_DRAWING {
-			drawingEvent := struct {
-				game.GameEvent
-				EventPayload game.DrawingEventPayload
-			}{}
-			err = json.Unmarshal([]byte(s), &drawingEvent)
-			if err != nil {
-				slog.Error("Error decoding drawing event", "error", err)
-			}
-			game.UpsertLine(p.Game, drawingEvent.EventPayload.Line, drawingEvent.EventPayload.Index)
-		}
-	})
+	if err := game.MarkConnected(claim.GameId, claim.PlayerId); err != nil {
+		slog.ErrorContext(r.Context(), "Error marking player connected", "error", err)
+	}
+}
+
+// Checks the token the same way the HTTP handlers do, and that its player is still in the game.
+func authorizePlayer(token string) (player.PlayerClaim, error) {
+	claim, err := player.DecodeToken(token)
+	if err != nil {
+		return claim, err
+	}
+
+	p := data.Player{}
+	err = data.GetDb().Get(&p, `SELECT game, active_state FROM player WHERE id = $1`, claim.PlayerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return claim, player.ErrUnauthorized
+	}
+	if err != nil {
+		return claim, err
+	}
+	if p.Game != claim.GameId {
+		return claim, player.ErrUnauthorized
+	}
+	if p.ActiveState == data.PLAYER_STATE_KICKED {
+		return claim, utils.ErrForbidden
+	}
+	return claim, nil
+}
+
+// Waits up to AUTH_TIMEOUT for a client to send its token.
+func readAuthMessage(ws *websocket.Conn) (player.PlayerClaim, error) {
+	ws.SetReadLimit(MAX_MESSAGE_SIZE)
+	ws.SetReadDeadline(time.Now().Add(AUTH_TIMEOUT))
+	defer ws.SetReadDeadline(time.Time{})
+
+	message := struct {
+		Token string `json:"token"`
+	}{}
+	if err := ws.ReadJSON(&message); err != nil {
+		return player.PlayerClaim{}, err
+	}
+	if message.Token == "" {
+		return player.PlayerClaim{}, player.ErrUnauthorized
+	}
+	return authorizePlayer(message.Token)
+}
+
+type connectionMessage struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func initMessage() connectionMessage {
+	return connectionMessage{
+		Kind:    "init-connection",
+		Message: "successfully connected",
+	}
+}
+
+func writeInitMessage(ws *websocket.Conn) {
+	ws.WriteJSON(initMessage())
 }
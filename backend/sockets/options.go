@@ -0,0 +1,34 @@
+package sockets
+
+import (
+	"scribl-clone/config"
+	"time"
+)
+
+// Incoming messages are limited to this many bytes, which fits a long drawing line
+const MAX_MESSAGE_SIZE = 1 << 20
+
+var connectionOptions = struct {
+	queueSize          int
+	writeTimeout       time.Duration
+	pongTimeout        time.Duration
+	slowConsumerPolicy string
+}{
+	queueSize:          256,
+	writeTimeout:       10 * time.Second,
+	pongTimeout:        60 * time.Second,
+	slowConsumerPolicy: SLOW_CONSUMER_SHED,
+}
+
+// Sets the limits of connections opened from now on.
+func Configure(cfg config.SocketsConfig) {
+	connectionOptions.queueSize = cfg.QueueSize
+	connectionOptions.writeTimeout = cfg.WriteTimeout
+	connectionOptions.pongTimeout = cfg.PongTimeout
+	connectionOptions.slowConsumerPolicy = cfg.SlowConsumerPolicy
+}
+
+// Pings are sent often enough that a pong arrives before the read deadline.
+func pingPeriod() time.Duration {
+	return connectionOptions.pongTimeout * 9 / 10
+}
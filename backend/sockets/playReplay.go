@@ -0,0 +1,90 @@
+package sockets
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/replay"
+	"scribl-clone/utils"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+)
+
+// Plays back a recorded game over a websocket, sending the events exactly as InitGameConnection
+// would have. The playback speed is set with ?speed=, defaulting to the original speed. Replays are
+// open to the same clients as spectating is.
+func PlayReplay(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	g, err := data.GetGame(gameId)
+	if err != nil || g == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := authorizeSpectator(r, g); err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	speed := 1.0
+	if rawSpeed := r.URL.Query().Get("speed"); rawSpeed != "" {
+		var err error
+		if speed, err = strconv.ParseFloat(rawSpeed, 64); err != nil || speed <= 0 {
+			http.Error(w, "Invalid speed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rep, err := replay.Load(gameId)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	if len(rep.Events) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+	defer ws.Close()
+
+	writeInitMessage(ws)
+
+	// Playback stops as soon as the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The replay is watched as a spectator, so events targeted at players are left out
+	err = rep.Play(ctx, speed, func(event []byte) error {
+		message, ok := game.PrepareForPlayer(string(event), "")
+		if !ok {
+			return nil
+		}
+		return ws.WriteMessage(websocket.TextMessage, []byte(message))
+	})
+	if err != nil && ctx.Err() == nil {
+		slog.Error("Error playing replay", "gameId", gameId, "error", err)
+		return
+	}
+
+	ws.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay finished"),
+	)
+}
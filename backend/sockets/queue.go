@@ -0,0 +1,160 @@
+package sockets
+
+import (
+	"errors"
+	"scribl-clone/game"
+	"scribl-clone/metrics"
+	"slices"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Skip superseded drawing frames and coalesce updates before giving up on a slow client
+	SLOW_CONSUMER_SHED = "shed"
+	// Disconnect a slow client as soon as its queue is full
+	SLOW_CONSUMER_DISCONNECT = "disconnect"
+)
+
+// Used for messages that aren't game events, like command replies
+const NOT_AN_EVENT = -1
+
+var ErrQueueFull = errors.New("outbound queue is full")
+
+type outboundMessage struct {
+	messageType int
+	data        []byte
+	eventType   int
+	// The line a drawing event upserts, read once when the message is made so full queues don't
+	// decode every queued frame on each push
+	lineIndex    int
+	hasLineIndex bool
+	// Closes the connection once the message is written
+	closeAfter bool
+}
+
+func newEventMessage(data []byte, eventType int) outboundMessage {
+	message := outboundMessage{messageType: websocket.TextMessage, data: data, eventType: eventType}
+	if eventType == game.GAME_EVENT_DRAWING {
+		message.lineIndex, message.hasLineIndex = game.ParseDrawingIndex(string(data))
+	}
+	return message
+}
+
+// Messages waiting to be written to a connection. It is filled by the redis listener and command
+// handlers and emptied by the connection's writer, which is woken through ready.
+type outboundQueue struct {
+	lock     sync.Mutex
+	messages []outboundMessage
+	size     int
+	policy   string
+	ready    chan struct{}
+}
+
+func newOutboundQueue() *outboundQueue {
+	return &outboundQueue{
+		size:   connectionOptions.queueSize,
+		policy: connectionOptions.slowConsumerPolicy,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// Queues a message without blocking. When the queue is full, the shed policy first merges the
+// message into the update queued right before it, then removes drawing frames superseded by a
+// later frame of the same line. Neither changes what the client ends up with. If that doesn't make
+// room ErrQueueFull is returned.
+func (q *outboundQueue) push(message outboundMessage) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// Close frames are always let through so the client learns why it is disconnected
+	if len(q.messages) < q.size || message.closeAfter {
+		q.append(message)
+		return nil
+	}
+	if q.policy == SLOW_CONSUMER_DISCONNECT {
+		return ErrQueueFull
+	}
+
+	if last := len(q.messages) - 1; game.IsCoalescableEvent(message.eventType) && q.messages[last].eventType == message.eventType {
+		if merged, ok := game.CoalesceEvents(string(q.messages[last].data), string(message.data)); ok {
+			q.messages[last].data = []byte(merged)
+			metrics.SlowConsumerActions.WithLabelValues("coalesced").Inc()
+			return nil
+		}
+	}
+
+	q.messages = append(q.messages, message)
+	if removed := q.removeSupersededDrawings(); removed > 0 {
+		metrics.SlowConsumerActions.WithLabelValues("superseded").Add(float64(removed))
+	}
+	if len(q.messages) > q.size {
+		// The message was appended last and nothing supersedes it, so it is still at the end
+		q.messages = q.messages[:len(q.messages)-1]
+		return ErrQueueFull
+	}
+	q.notify()
+	return nil
+}
+
+// Removes drawing frames that a later frame of the same line replaces. Line indexes start again
+// each turn, and turns are ended by game updates, so frames on either side of a game update are
+// never compared. Returns how many frames were removed.
+func (q *outboundQueue) removeSupersededDrawings() int {
+	seen := map[int]bool{}
+	kept := make([]outboundMessage, 0, len(q.messages))
+	// Walk backwards so the latest frame of each line is the one kept
+	for i := len(q.messages) - 1; i >= 0; i-- {
+		message := q.messages[i]
+		switch message.eventType {
+		case game.GAME_EVENT_GAME_UPDATE:
+			clear(seen)
+		case game.GAME_EVENT_DRAWING:
+			if message.hasLineIndex {
+				if seen[message.lineIndex] {
+					continue
+				}
+				seen[message.lineIndex] = true
+			}
+		}
+		kept = append(kept, message)
+	}
+	slices.Reverse(kept)
+
+	removed := len(q.messages) - len(kept)
+	q.messages = kept
+	return removed
+}
+
+func (q *outboundQueue) append(message outboundMessage) {
+	q.messages = append(q.messages, message)
+	q.notify()
+}
+
+// Wakes the writer.
+func (q *outboundQueue) notify() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *outboundQueue) pop() (outboundMessage, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.messages) == 0 {
+		return outboundMessage{}, false
+	}
+	message := q.messages[0]
+	q.messages[0] = outboundMessage{}
+	q.messages = q.messages[1:]
+	return message, true
+}
+
+func (q *outboundQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.messages)
+}
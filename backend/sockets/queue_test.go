@@ -0,0 +1,201 @@
+package sockets
+
+import (
+	"encoding/json"
+	"errors"
+	"scribl-clone/game"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestQueue(size int, policy string) *outboundQueue {
+	return &outboundQueue{size: size, policy: policy, ready: make(chan struct{}, 1)}
+}
+
+func encodeEvent(t testing.TB, eventType int, payload any) outboundMessage {
+	t.Helper()
+	data, err := json.Marshal(game.GameEvent{EventType: eventType, EventPayload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newEventMessage(data, eventType)
+}
+
+// A drawing frame of a line, with the version stored in the line's size so tests can tell frames
+// of the same line apart.
+func drawingFrame(t testing.TB, index int, version int) outboundMessage {
+	return encodeEvent(t, game.GAME_EVENT_DRAWING, game.DrawingEventPayload{Index: index, Line: game.Line{Size: version}})
+}
+
+func gameUpdate(t testing.TB, updates game.GameUpdatePayload) outboundMessage {
+	return encodeEvent(t, game.GAME_EVENT_GAME_UPDATE, updates)
+}
+
+func chat(t testing.TB, text string) outboundMessage {
+	return encodeEvent(t, game.GAME_EVENT_CHAT, map[string]string{"message": text})
+}
+
+func frameVersion(t testing.TB, message outboundMessage) (int, int) {
+	t.Helper()
+	event := struct{ EventPayload game.DrawingEventPayload }{}
+	if err := json.Unmarshal(message.data, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event.EventPayload.Index, event.EventPayload.Line.Size
+}
+
+func mustPush(t *testing.T, q *outboundQueue, messages ...outboundMessage) {
+	t.Helper()
+	for _, message := range messages {
+		if err := q.push(message); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+}
+
+func queuedData(q *outboundQueue) []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	data := make([]string, len(q.messages))
+	for i, message := range q.messages {
+		data[i] = string(message.data)
+	}
+	return data
+}
+
+func TestPushCoalescesAdjacentUpdates(t *testing.T) {
+	q := newTestQueue(2, SLOW_CONSUMER_SHED)
+	mustPush(t, q, chat(t, "hi"), gameUpdate(t, game.GameUpdatePayload{"state": 2}))
+	mustPush(t, q, gameUpdate(t, game.GameUpdatePayload{"currentRound": 3}))
+
+	queued := queuedData(q)
+	if len(queued) != 2 {
+		t.Fatalf("queued %d messages, want 2", len(queued))
+	}
+	want := string(gameUpdate(t, game.GameUpdatePayload{"currentRound": 3, "state": 2}).data)
+	if queued[1] != want {
+		t.Errorf("merged update = %s, want %s", queued[1], want)
+	}
+}
+
+func TestPushDoesNotCoalesceAcrossOtherEvents(t *testing.T) {
+	q := newTestQueue(2, SLOW_CONSUMER_SHED)
+	mustPush(t, q, gameUpdate(t, game.GameUpdatePayload{"state": 2}), chat(t, "hi"))
+	before := queuedData(q)
+
+	if err := q.push(gameUpdate(t, game.GameUpdatePayload{"state": 3})); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("push = %v, want ErrQueueFull", err)
+	}
+	if after := queuedData(q); len(after) != 2 || after[0] != before[0] || after[1] != before[1] {
+		t.Errorf("queue changed to %v", after)
+	}
+}
+
+func TestPushSupersedesDrawingsOfTheSameLine(t *testing.T) {
+	q := newTestQueue(3, SLOW_CONSUMER_SHED)
+	mustPush(t, q, drawingFrame(t, 0, 1), drawingFrame(t, 1, 1), chat(t, "hi"))
+	mustPush(t, q, drawingFrame(t, 0, 2))
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.messages) != 3 {
+		t.Fatalf("queued %d messages, want 3", len(q.messages))
+	}
+	if index, version := frameVersion(t, q.messages[0]); index != 1 || version != 1 {
+		t.Errorf("first message is line %d version %d, want line 1 version 1", index, version)
+	}
+	if index, version := frameVersion(t, q.messages[2]); index != 0 || version != 2 {
+		t.Errorf("last message is line %d version %d, want line 0 version 2", index, version)
+	}
+}
+
+func TestPushKeepsDrawingsFromEarlierTurns(t *testing.T) {
+	q := newTestQueue(3, SLOW_CONSUMER_SHED)
+	// Line 0 of the next turn is a different line
+	mustPush(t, q, drawingFrame(t, 0, 1), gameUpdate(t, game.GameUpdatePayload{"state": 3}), chat(t, "hi"))
+
+	if err := q.push(drawingFrame(t, 0, 2)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("push = %v, want ErrQueueFull", err)
+	}
+	if queued := q.len(); queued != 3 {
+		t.Errorf("queued %d messages, want 3", queued)
+	}
+}
+
+func TestPushDisconnectPolicy(t *testing.T) {
+	q := newTestQueue(1, SLOW_CONSUMER_DISCONNECT)
+	mustPush(t, q, drawingFrame(t, 0, 1))
+
+	if err := q.push(drawingFrame(t, 0, 2)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("push = %v, want ErrQueueFull", err)
+	}
+	if err := q.push(outboundMessage{messageType: websocket.CloseMessage, closeAfter: true}); err != nil {
+		t.Errorf("push of a close frame = %v, want it let through", err)
+	}
+}
+
+// Producers outrun the consumer so frames are superseded while the queue is being emptied. Every
+// line's frames must still arrive in order and end with its latest frame.
+func TestPushAndPopConcurrently(t *testing.T) {
+	const (
+		producers = 4
+		lines     = 8
+		versions  = 500
+	)
+	// Room for the latest frame of every line, so superseding always makes space
+	q := newTestQueue(producers*lines, SLOW_CONSUMER_SHED)
+
+	var wg sync.WaitGroup
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for version := 1; version <= versions; version++ {
+				for line := range lines {
+					if err := q.push(drawingFrame(t, p*lines+line, version)); err != nil {
+						t.Errorf("push: %v", err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	produced := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(produced)
+	}()
+
+	latest := map[int]int{}
+	receive := func() {
+		for {
+			message, ok := q.pop()
+			if !ok {
+				return
+			}
+			index, version := frameVersion(t, message)
+			if version <= latest[index] {
+				t.Errorf("line %d version %d arrived after version %d", index, version, latest[index])
+			}
+			latest[index] = version
+		}
+	}
+	for done := false; !done; {
+		select {
+		case <-q.ready:
+		case <-produced:
+			done = true
+		}
+		receive()
+	}
+	receive()
+
+	for index := range producers * lines {
+		if latest[index] != versions {
+			t.Errorf("line %d ended at version %d, want %d", index, latest[index], versions)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package sockets
+
+import "sync"
+
+// The open connections of this process, by connection id. Connections are added by the HTTP
+// handlers and removed from the redis listener and the connections' own goroutines.
+type registry struct {
+	lock        sync.RWMutex
+	connections map[string]*Connection
+}
+
+var connections = &registry{connections: make(map[string]*Connection)}
+
+// Registers a connection, returning the connection it replaces if one with the same id was open.
+// A player reconnecting after a network drop takes over from their half-open connection.
+func (r *registry) add(conn *Connection) *Connection {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	replaced := r.connections[conn.id]
+	r.connections[conn.id] = conn
+	return replaced
+}
+
+// Removes a connection, reporting whether it was still registered so only one caller closes it.
+// A connection that was replaced is no longer registered, so its replacement is left alone.
+func (r *registry) remove(conn *Connection) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.connections[conn.id] != conn {
+		return false
+	}
+	delete(r.connections, conn.id)
+	return true
+}
+
+func (r *registry) get(id string) (*Connection, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	conn, ok := r.connections[id]
+	return conn, ok
+}
+
+// Returns the connections open at the time of the call.
+func (r *registry) all() []*Connection {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	all := make([]*Connection, 0, len(r.connections))
+	for _, conn := range r.connections {
+		all = append(all, conn)
+	}
+	return all
+}
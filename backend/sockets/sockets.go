@@ -3,10 +3,18 @@ package sockets
 // This module contains functionality to create websocket connections that listen to redis connection
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"scribl-clone/eventListener"
+	"scribl-clone/game"
+	"scribl-clone/logging"
+	"scribl-clone/metrics"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -27,35 +35,70 @@ var EXPECTED_CLOSE_ERRORS = []int{
 	websocket.CloseTLSHandshake,
 }
 
+// A websocket listening to a redis channel. gorilla/websocket allows only one concurrent writer,
+// so messages are queued and written by the connection's own goroutine.
 type Connection struct {
-	id       string
-	channel  string
-	ws       *websocket.Conn
-	callback func(string)
-}
+	id      string
+	channel string
+	// Unique to this connection, unlike id which a reconnecting player reuses
+	subscriptionId string
+	playerId       string
+	ws             *websocket.Conn
+	onMessage      func(conn *Connection, message string)
+	// Carries the connection's ids to the records it logs
+	ctx context.Context
 
-func (c *Connection) SetCallback(callback func(string)) {
-	c.callback = callback
+	outbound  *outboundQueue
+	done      chan struct{}
+	closeOnce sync.Once
+	// Orders subscribing against closing, so a closed connection never stays subscribed
+	subscribeLock sync.Mutex
 }
 
-var connections = make(map[string]*Connection)
-
-func CreateConnection(channel string, id string, ws *websocket.Conn) (*Connection, error) {
-	_, ok := connections[id]
-	if ok {
-		return nil, errors.New("connection with this id already exists")
+// Creates a connection that receives the events published to channel. Events targeted at an
+// audience are only sent if the connection's player is part of it, an empty playerId makes the
+// connection a spectator. onMessage is called with each text message the client sends.
+func CreateConnection(channel string, id string, playerId string, ws *websocket.Conn, onMessage func(conn *Connection, message string)) (*Connection, error) {
+	if onMessage == nil {
+		onMessage = func(*Connection, string) {}
 	}
-
 	conn := &Connection{
-		channel:  channel,
-		id:       id,
-		ws:       ws,
-		callback: func(s string) {},
+		channel:   channel,
+		id:        id,
+		playerId:  playerId,
+		ws:        ws,
+		onMessage: onMessage,
+		ctx:       connectionContext(channel, id, playerId),
+		outbound:  newOutboundQueue(),
+		done:      make(chan struct{}),
+
+		subscriptionId: id + "/" + uuid.NewString(),
+	}
+	if replaced := connections.add(conn); replaced != nil {
+		slog.DebugContext(conn.ctx, "replacing connection")
+		go replaced.replace()
 	}
-	connections[id] = conn
+	metrics.WebsocketsConnected.Inc()
 
-	eventListener.Subscribe(channel, id, func(data string) {
-		ws.WriteMessage(websocket.TextMessage, []byte(data))
+	go conn.writeMessages()
+	// Queued before subscribing so it is the first message the client receives
+	conn.writeJSON(initMessage())
+
+	conn.subscribe(func(data string) {
+		message, ok := game.PrepareForPlayer(data, playerId)
+		if !ok {
+			return
+		}
+		eventType, ok := game.ParseEventType(message)
+		if !ok {
+			eventType = NOT_AN_EVENT
+		}
+		if err := conn.writeEvent([]byte(message), eventType); err == nil {
+			metrics.EventsDelivered.WithLabelValues(game.EventTypeName(eventType)).Inc()
+		}
+		if game.IsKickedEvent(message, playerId) {
+			conn.closeWith(websocket.ClosePolicyViolation, "kicked")
+		}
 	})
 
 	go readMessages(conn)
@@ -63,29 +106,233 @@ func CreateConnection(channel string, id string, ws *websocket.Conn) (*Connectio
 	return conn, nil
 }
 
+// Subscribes to the connection's channel unless the connection was closed first, as when it is
+// replaced while it is still being created.
+func (c *Connection) subscribe(fn func(data string)) {
+	c.subscribeLock.Lock()
+	defer c.subscribeLock.Unlock()
+
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	eventListener.Subscribe(c.channel, c.subscriptionId, fn)
+}
+
+func connectionContext(channel string, id string, playerId string) context.Context {
+	args := []any{"connectionId", id}
+	if gameId, ok := game.ParseGameChannelName(channel); ok {
+		args = append(args, "gameId", gameId)
+	}
+	if playerId != "" {
+		args = append(args, "playerId", playerId)
+	}
+	return logging.With(context.Background(), args...)
+}
+
+// Queues a message without blocking. A client that lets its queue fill up is disconnected.
+func (c *Connection) enqueue(message outboundMessage) error {
+	select {
+	case <-c.done:
+		return websocket.ErrCloseSent
+	default:
+	}
+	err := c.outbound.push(message)
+	if errors.Is(err, ErrQueueFull) {
+		slog.WarnContext(c.ctx, "closing slow connection", "queued", c.outbound.len())
+		metrics.SlowConsumerActions.WithLabelValues("disconnected").Inc()
+		go c.disconnect(websocket.CloseTryAgainLater, "too slow")
+	}
+	return err
+}
+
+// Closes the connection without waiting for the queue to drain.
+func (c *Connection) disconnect(code int, reason string) {
+	// WriteControl may be called alongside the connection's writer
+	c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	closeConnection(c, true)
+}
+
+// Closes a connection a new one with the same id has taken over from. Its player is still
+// connected, so they aren't marked as disconnected.
+func (c *Connection) replace() {
+	c.ws.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "replaced by a new connection"),
+		time.Now().Add(time.Second),
+	)
+	c.close()
+}
+
+func (c *Connection) writeMessage(message []byte) error {
+	return c.writeEvent(message, NOT_AN_EVENT)
+}
+
+func (c *Connection) writeEvent(message []byte, eventType int) error {
+	return c.enqueue(newEventMessage(message, eventType))
+}
+
+func (c *Connection) writeJSON(v any) error {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeMessage(message)
+}
+
+// Sends a close frame after the messages already queued, then closes the connection.
+func (c *Connection) closeWith(code int, reason string) error {
+	return c.enqueue(outboundMessage{
+		messageType: websocket.CloseMessage,
+		data:        websocket.FormatCloseMessage(code, reason),
+		eventType:   NOT_AN_EVENT,
+		closeAfter:  true,
+	})
+}
+
+// The connection's only writer, running until the connection is closed. It also pings the
+// client, whose pongs keep the read deadline from passing.
+func (c *Connection) writeMessages() {
+	ticker := time.NewTicker(pingPeriod())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(connectionOptions.writeTimeout)
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				slog.DebugContext(c.ctx, "Error pinging websocket", "error", err)
+				closeConnection(c, true)
+				return
+			}
+			continue
+		case <-c.outbound.ready:
+		}
+
+		for {
+			message, ok := c.outbound.pop()
+			if !ok {
+				break
+			}
+			deadline := time.Now().Add(connectionOptions.writeTimeout)
+			var err error
+			if message.messageType == websocket.CloseMessage {
+				err = c.ws.WriteControl(message.messageType, message.data, deadline)
+			} else {
+				c.ws.SetWriteDeadline(deadline)
+				err = c.ws.WriteMessage(message.messageType, message.data)
+			}
+			if err != nil || message.closeAfter {
+				if err != nil {
+					slog.DebugContext(c.ctx, "Error writing to websocket", "error", err)
+				}
+				closeConnection(c, true)
+				return
+			}
+		}
+	}
+}
+
+// Reads until the client goes away. Any read error closes the connection, including the read
+// deadline passing because a half-open connection stopped answering pings.
 func readMessages(conn *Connection) {
+	defer closeConnection(conn, true)
+
+	extendDeadline := func() error {
+		return conn.ws.SetReadDeadline(time.Now().Add(connectionOptions.pongTimeout))
+	}
+	conn.ws.SetReadLimit(MAX_MESSAGE_SIZE)
+	extendDeadline()
+	conn.ws.SetPongHandler(func(string) error { return extendDeadline() })
+
 	for {
 		messageType, message, err := conn.ws.ReadMessage()
-		if closeErr, ok := err.(*websocket.CloseError); ok {
-			if websocket.IsUnexpectedCloseError(closeErr, EXPECTED_CLOSE_ERRORS...) {
-				slog.Error(closeErr.Error())
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				slog.DebugContext(conn.ctx, "connection lost", "error", err)
+			} else if websocket.IsUnexpectedCloseError(closeErr, EXPECTED_CLOSE_ERRORS...) {
+				slog.ErrorContext(conn.ctx, closeErr.Error())
 			}
-			CloseConnection(conn.id)
 			return
 		}
+		extendDeadline()
 		if messageType == websocket.TextMessage {
-			conn.callback(string(message))
+			conn.onMessage(conn, string(message))
 		}
 	}
 }
 
+// Closes the connection registered under id, if any.
 func CloseConnection(id string) {
-	conn, ok := connections[id]
-	if !ok {
+	if conn, ok := connections.get(id); ok {
+		closeConnection(conn, true)
+	}
+}
+
+// Closes a connection, marking its player as disconnected unless announce is false, as when the
+// server restarts and the client is expected back shortly.
+func closeConnection(conn *Connection, announce bool) {
+	registered := connections.remove(conn)
+	conn.close()
+	if !registered {
 		return
 	}
-	conn.ws.Close()
-	eventListener.Unsubscribe(conn.channel, conn.id)
-	delete(connections, id)
-	slog.Debug("closing connection", "connectionId", id)
+	slog.DebugContext(conn.ctx, "closing connection")
+
+	if !announce || conn.playerId == "" {
+		return
+	}
+	// The player may have reconnected already
+	if _, reconnected := connections.get(conn.id); reconnected {
+		return
+	}
+	if gameId, ok := game.ParseGameChannelName(conn.channel); ok {
+		if err := game.MarkDisconnected(gameId, conn.playerId); err != nil {
+			slog.ErrorContext(conn.ctx, "Error marking player disconnected", "error", err)
+		}
+	}
+}
+
+func (c *Connection) close() {
+	c.closeOnce.Do(func() {
+		c.subscribeLock.Lock()
+		close(c.done)
+		c.subscribeLock.Unlock()
+		c.ws.Close()
+		eventListener.Unsubscribe(c.channel, c.subscriptionId)
+		metrics.WebsocketsConnected.Dec()
+	})
+}
+
+// Reports whether the player has a websocket open to this process.
+func HasPlayerConnection(playerId string) bool {
+	conn, ok := connections.get(playerId)
+	return ok && conn.playerId == playerId
+}
+
+// Returns the number of open connections on each channel.
+func ConnectionCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, conn := range connections.all() {
+		counts[conn.channel]++
+	}
+	return counts
+}
+
+// Tells every client the server is restarting and closes their connections, giving each client
+// until the deadline to receive the close frame.
+func CloseAll(deadline time.Time) {
+	for _, conn := range connections.all() {
+		// WriteControl may be called alongside the connection's writer
+		conn.ws.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting"),
+			deadline,
+		)
+		closeConnection(conn, false)
+	}
 }
@@ -0,0 +1,257 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"scribl-clone/config"
+	"scribl-clone/eventListener"
+	"scribl-clone/game"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+)
+
+var testRedis *miniredis.Miniredis
+
+func TestMain(m *testing.M) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	testRedis = mr
+	eventListener.Connect(config.RedisConfig{Addr: mr.Addr()})
+
+	code := m.Run()
+	eventListener.Close()
+	mr.Close()
+	os.Exit(code)
+}
+
+// A server opening a spectator connection to channel for each client, under the id in the id
+// query parameter. Spectators aren't marked disconnected, so no database is needed.
+type testServer struct {
+	*httptest.Server
+	channel string
+	created chan *Connection
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{
+		channel: "test/" + strings.ReplaceAll(t.Name(), "/", "-"),
+		created: make(chan *Connection, 64),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn, err := CreateConnection(s.channel, r.URL.Query().Get("id"), "", ws, nil)
+		if err != nil {
+			ws.Close()
+			return
+		}
+		s.created <- conn
+	}))
+	t.Cleanup(func() {
+		CloseAll(time.Now().Add(time.Second))
+		s.Close()
+	})
+	return s
+}
+
+// Opens a client connection and waits for the server side to be subscribed.
+func (s *testServer) dial(t *testing.T, id string) (*websocket.Conn, *Connection) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "?id=" + id
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	var conn *Connection
+	select {
+	case conn = <-s.created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection wasn't created")
+	}
+	waitFor(t, "redis subscription", func() bool {
+		return testRedis.PubSubNumSub(s.channel)[s.channel] > 0
+	})
+
+	if _, message, err := ws.ReadMessage(); err != nil || !strings.Contains(string(message), "init-connection") {
+		t.Fatalf("first message = %s, %v, want the init message", message, err)
+	}
+	return ws, conn
+}
+
+func (s *testServer) publish(t testing.TB, message string) {
+	if err := eventListener.GetPubSub().Publish(context.Background(), s.channel, message).Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func chatEvent(t testing.TB, text string) string {
+	return string(chat(t, text).data)
+}
+
+func readText(t *testing.T, ws *websocket.Conn) string {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(message)
+}
+
+func TestConnectionDeliversEventsInOrder(t *testing.T) {
+	s := newTestServer(t)
+	ws, _ := s.dial(t, "player")
+
+	for i := range 20 {
+		s.publish(t, chatEvent(t, fmt.Sprint(i)))
+	}
+	for i := range 20 {
+		if got, want := readText(t, ws), chatEvent(t, fmt.Sprint(i)); got != want {
+			t.Fatalf("message %d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestReconnectReplacesConnection(t *testing.T) {
+	s := newTestServer(t)
+	oldWs, oldConn := s.dial(t, "player")
+	newWs, newConn := s.dial(t, "player")
+
+	oldWs.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := oldWs.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("old connection read %v, want a policy violation close", err)
+	}
+	<-oldConn.done
+
+	// The old connection's goroutines closing it must leave the new one registered and subscribed
+	closeConnection(oldConn, true)
+	if conn, ok := connections.get("player"); !ok || conn != newConn {
+		t.Fatal("the new connection isn't registered")
+	}
+	if subscribed := eventListener.SubscriptionCounts()[s.channel]; subscribed != 1 {
+		t.Fatalf("%d subscriptions to the channel, want 1", subscribed)
+	}
+
+	s.publish(t, chatEvent(t, "hello"))
+	if got, want := readText(t, newWs), chatEvent(t, "hello"); got != want {
+		t.Errorf("new connection read %s, want %s", got, want)
+	}
+}
+
+func TestCloseConnectionUnregisters(t *testing.T) {
+	s := newTestServer(t)
+	ws, conn := s.dial(t, "player")
+
+	CloseConnection("player")
+	<-conn.done
+	if _, ok := connections.get("player"); ok {
+		t.Error("closed connection is still registered")
+	}
+	waitFor(t, "unsubscribe", func() bool {
+		return eventListener.SubscriptionCounts()[s.channel] == 0
+	})
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := ws.ReadMessage(); err == nil {
+		t.Error("client could still read after the connection was closed")
+	}
+}
+
+// Meant for the race detector: events are published while clients reconnect under the same ids
+// and connections are closed from both ends. Afterwards nothing may be left registered or
+// subscribed.
+func TestPublishWhileReconnecting(t *testing.T) {
+	s := newTestServer(t)
+
+	stop := make(chan struct{})
+	var publishing sync.WaitGroup
+	publishing.Add(1)
+	go func() {
+		defer publishing.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// Drawing frames of a few lines and game updates, so slow clients shed and coalesce
+			message := drawingFrame(t, i%4, i)
+			if i%5 == 0 {
+				message = gameUpdate(t, game.GameUpdatePayload{"round": i})
+			}
+			s.publish(t, string(message.data))
+		}
+	}()
+
+	var clients sync.WaitGroup
+	for c := range 4 {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			id := fmt.Sprint("player", c%2)
+			for round := range 10 {
+				url := "ws" + strings.TrimPrefix(s.URL, "http") + "?id=" + id
+				ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ws.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				for range round {
+					if _, _, err := ws.ReadMessage(); err != nil {
+						break
+					}
+				}
+				if round%3 == 0 {
+					CloseConnection(id)
+				}
+				ws.Close()
+			}
+		}()
+	}
+	go func() {
+		for range s.created {
+		}
+	}()
+	clients.Wait()
+	close(stop)
+	publishing.Wait()
+
+	waitFor(t, "connections to close", func() bool {
+		for _, conn := range connections.all() {
+			if conn.channel == s.channel {
+				return false
+			}
+		}
+		return eventListener.SubscriptionCounts()[s.channel] == 0
+	})
+}
@@ -0,0 +1,59 @@
+package sockets
+
+import (
+	"net/http"
+	"scribl-clone/data"
+	"scribl-clone/game"
+	"scribl-clone/player"
+	"scribl-clone/utils"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// Opens a read only connection to a game. Spectators receive the events every player receives,
+// but none of the events targeted at particular players. Games that aren't open to spectators can
+// only be watched by their players, who send their token as in InitGameConnection.
+func SpectateGame(w http.ResponseWriter, r *http.Request) {
+	gameId := chi.URLParam(r, "gameId")
+
+	g, err := data.GetGame(gameId)
+	if err != nil || g == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := authorizeSpectator(r, g); err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		utils.HandleError(w, r, err)
+		return
+	}
+
+	connectionId := "spectator/" + uuid.NewString()
+	if _, err := CreateConnection(game.GetGameChannelName(gameId), connectionId, "", ws, nil); err != nil {
+		ws.Close()
+	}
+}
+
+// Lets anyone watch a game that is open to spectators, and only its players otherwise.
+func authorizeSpectator(r *http.Request, g *data.Game) error {
+	if game.OpenToSpectators(g) {
+		return nil
+	}
+	token := player.GetRequestToken(r)
+	if token == "" {
+		return player.ErrUnauthorized
+	}
+	claim, err := authorizePlayer(token)
+	if err != nil {
+		return err
+	}
+	if claim.GameId != g.Id {
+		return utils.ErrForbidden
+	}
+	return nil
+}
@@ -4,3 +4,5 @@ import "errors"
 
 var ErrInvalidArguments = errors.New("invalid arguments")
 var ErrResourceNotFound = errors.New("resource not found")
+var ErrTooManyRequests = errors.New("too many requests")
+var ErrForbidden = errors.New("forbidden")
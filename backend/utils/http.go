@@ -4,13 +4,14 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"scribl-clone/moderation"
 	"scribl-clone/player"
 )
 
 var STANDARD_SUCCESS_RESPONSE = []byte(`{"message": "success"}`)
 
-func HandleError(w http.ResponseWriter, err error) {
-	slog.Debug(err.Error())
+func HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.DebugContext(r.Context(), err.Error())
 	if errors.Is(err, ErrResourceNotFound) {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
@@ -19,10 +20,22 @@ func HandleError(w http.ResponseWriter, err error) {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, moderation.ErrRejected) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrTooManyRequests) {
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, ErrForbidden) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
 	if errors.Is(err, player.ErrUnauthorized) {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	slog.Error(err.Error())
+	slog.ErrorContext(r.Context(), err.Error())
 	http.Error(w, http.StatusText(500), 500)
 }
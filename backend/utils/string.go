@@ -7,9 +7,15 @@ import (
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func RandStringRunes(n int) string {
+	return RandStringFrom(string(letterRunes), n)
+}
+
+// Returns a random string of n characters picked from alphabet.
+func RandStringFrom(alphabet string, n int) string {
+	runes := []rune(alphabet)
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+		b[i] = runes[rand.Intn(len(runes))]
 	}
 	return string(b)
 }
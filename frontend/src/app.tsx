@@ -71,6 +71,7 @@ function Main() {
     const gameState = useAppSelector((state) => state.game);
     const [lines, setLines] = useState<Line[]>([]);
     const [guesses, setGuesses] = useState<Guess[]>([])
+    const [wordChoices, setWordChoices] = useState<string[]>([]);
 
     const onDraw = useCallback((lines: Line[]) => {
         const i = lines.length - 1;
@@ -116,6 +117,12 @@ function Main() {
         }
     }, [gameState.dataState, gameState.game?.state]);
 
+    useEffect(() => {
+        return gameWebsocket.addEventListener(GameEventTypes.WORD_CHOICES, ({ payload }) => {
+            setWordChoices(payload);
+        });
+    }, []);
+
     useEffect(() => {
         const guessCleanup = gameWebsocket.addEventListener(GameEventTypes.GUESS_OCCURRED, ({payload}) => {
             setGuesses((prevValue) => {
@@ -153,7 +160,7 @@ function Main() {
                     transform: 'translate(0,-50%)',
                 }}>
                 <h1>Pick a word</h1>
-                {['a', 'b', 'c'].map((word) => (
+                {wordChoices.map((word) => (
                     <button key={word} onClick={() => apiSelectWord(game.id, word)}>
                         {word}
                     </button>
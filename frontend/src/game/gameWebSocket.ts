@@ -22,6 +22,7 @@ export const GameEventTypes = {
     PLAYER_UPDATE: 4,
     PLAYER_ADDED: 5,
     DRAWING: 6,
+    WORD_CHOICES: 8,
 } as const;
 
 export type GameEventType = (typeof GameEventTypes)[keyof typeof GameEventTypes];
@@ -75,6 +76,12 @@ type DrawingEvent = {
     };
 };
 
+// Only sent to the drawer
+type WordChoicesEvent = {
+    type: (typeof GameEventTypes)['WORD_CHOICES'];
+    payload: string[];
+};
+
 type GuessEvent = {
     type: (typeof GameEventTypes)['GUESS_OCCURRED'];
     payload: {
@@ -90,6 +97,7 @@ type GameWebsocketEvent =
     | PlayerAddedEvent
     | GameUpdateEvent
     | DrawingEvent
+    | WordChoicesEvent
     | GuessEvent;
 
 function createConnection(url: string) {
@@ -127,7 +135,7 @@ export class GameWebsocket {
         this.ws?.send(JSON.stringify(payload));
     }
 
-    public async setPlayerId(gameId: string) {
+    public async setPlayerId(gameId: string, authToken: string) {
         if (!gameId || gameId === this.gameId) {
             return;
         }
@@ -136,6 +144,8 @@ export class GameWebsocket {
         this.ws?.close();
 
         this.ws = await createConnection(`${WEBSOCKET_HOST}/game_connection/${this.gameId}`);
+        // Sent as the first message rather than in the URL, which ends up in access logs
+        this.ws.send(JSON.stringify({ token: authToken }));
 
         this.ws.onerror = () => {
             console.error('Websockets encountered an error');
@@ -183,15 +193,16 @@ const gameWebSocket = GameWebsocket.getInstance();
 export function useInitialiseData() {
     const playerId = useAppSelector((state) => state.game.playerId);
     const gameId = useAppSelector((state) => state.game.gameId);
+    const authToken = useAppSelector((state) => state.game.authToken);
     const dispatch = useAppDispatch();
 
     useEffect(() => {
-        if (!playerId || !gameId) {
+        if (!playerId || !gameId || !authToken) {
             return;
         }
         dispatch(setLoadingState('loading'));
 
-        gameWebSocket.setPlayerId(playerId);
+        gameWebSocket.setPlayerId(playerId, authToken);
         dispatch(clearGame());
         Promise.all([
             getGame(gameId).then((game) => dispatch(setGame(game))),
@@ -199,7 +210,7 @@ export function useInitialiseData() {
         ]).then(() => {
             dispatch(setLoadingState('loaded'));
         });
-    }, [playerId, gameId, dispatch]);
+    }, [playerId, gameId, authToken, dispatch]);
 }
 
 export function useGameWebsocket() {